@@ -0,0 +1,191 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+// Command conform checks an external implementation of dot against
+// the compact JSON test suites.
+//
+// Usage:
+//
+//	conform [-v] [-suite path]... command [args...]
+//
+// The command is started as a subprocess and is sent one test case at
+// a time as a single line of JSON on its standard input:
+//
+//	{"id":"splices.json/3","input":"ab","left":["(a=)b"],"right":["a(b=)"]}
+//
+// For each such line, it must write a single line of JSON to its
+// standard output with the result of merging left and right:
+//
+//	{"id":"splices.json/3","transformed":["(b=)"],"rebased":["(a=)"]}
+//
+// where transformed applies after left and rebased applies after
+// right. An implementation that cannot handle a case can respond with
+// {"id": ..., "error": "reason"} instead.  The standard input is closed
+// once all the cases have been sent.
+//
+// All the changes are in the compact form described in
+// github.com/dotchain/dataset/CompactJSON.md.  Every case is reported
+// as passing or failing and the command exits with a non-zero status
+// if any case fails.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/dotchain/dataset/suite"
+)
+
+type request struct {
+	ID    string   `json:"id"`
+	Input string   `json:"input"`
+	Left  []string `json:"left"`
+	Right []string `json:"right"`
+}
+
+type response struct {
+	ID          string   `json:"id"`
+	Transformed []string `json:"transformed"`
+	Rebased     []string `json:"rebased"`
+	Error       string   `json:"error"`
+}
+
+type files []string
+
+func (f *files) String() string     { return strings.Join(*f, ",") }
+func (f *files) Set(s string) error { *f = append(*f, s); return nil }
+
+func main() {
+	var paths files
+	flag.Var(&paths, "suite", "path of a compact suite (repeatable, defaults to all bundled suites)")
+	verbose := flag.Bool("v", false, "report passing cases as well")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: conform [-v] [-suite path]... command [args...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if len(paths) == 0 {
+		paths = suite.Files()
+	}
+
+	cmd := exec.Command(flag.Arg(0), flag.Args()[1:]...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		log.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		log.Fatal(err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(nil, 16*1024*1024)
+	i := &impl{json.NewEncoder(stdin), scanner}
+
+	passed, failed := 0, 0
+	for _, path := range paths {
+		s, err := suite.Load(path)
+		if err != nil {
+			log.Fatal(path, ": ", err)
+		}
+
+		for kk, test := range s.Tests {
+			id := fmt.Sprintf("%s/%d", filepath.Base(path), kk)
+			if err := i.check(id, test); err != nil {
+				failed++
+				fmt.Printf("FAIL %s: %v\n", id, err)
+			} else {
+				passed++
+				if *verbose {
+					fmt.Printf("PASS %s\n", id)
+				}
+			}
+		}
+	}
+
+	if err := stdin.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if err := cmd.Wait(); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("%d passed, %d failed\n", passed, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// impl is the external implementation being checked
+type impl struct {
+	*json.Encoder
+	*bufio.Scanner
+}
+
+// check sends a single test case to the implementation and compares
+// its response with the expected one.  Failures to communicate with
+// the implementation are fatal and so is a response with the wrong
+// id as the remaining responses cannot be matched up with the cases.
+func (i *impl) check(id string, test suite.Test) error {
+	req := request{id, test.Input, test.Left, test.Right}
+	if err := i.Encode(req); err != nil {
+		log.Fatal(err)
+	}
+
+	if !i.Scan() {
+		err := i.Err()
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		log.Fatal("reading response for ", id, ": ", err)
+	}
+
+	var res response
+	if err := json.Unmarshal(i.Bytes(), &res); err != nil {
+		log.Fatal("invalid response for ", id, ": ", err)
+	}
+
+	if res.ID != id {
+		log.Fatalf("response for %s has id %q", id, res.ID)
+	}
+
+	switch {
+	case res.Error != "":
+		return fmt.Errorf("%s (%v)", res.Error, test)
+	case !equal(res.Transformed, test.Transformed):
+		return fmt.Errorf("transformed = %q (%v)", res.Transformed, test)
+	case !equal(res.Rebased, test.Rebased):
+		return fmt.Errorf("rebased = %q (%v)", res.Rebased, test)
+	}
+	return nil
+}
+
+func equal(actual, expected []string) bool {
+	if len(actual) != len(expected) {
+		return false
+	}
+	for kk := range actual {
+		if actual[kk] != expected[kk] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dotchain/dataset/suite"
+)
+
+// TestMain runs the test binary as conform itself when CONFORM_ARGS
+// is set and as the implementation being checked when invoked with
+// -child.
+func TestMain(m *testing.M) {
+	if len(os.Args) == 4 && os.Args[1] == "-child" {
+		child(os.Args[2], os.Args[3])
+		os.Exit(0)
+	}
+	if args := os.Getenv("CONFORM_ARGS"); args != "" {
+		os.Args = append([]string{"conform"}, strings.Fields(args)...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// child is a fake implementation that answers from the suite at path.
+// The mode picks how it misbehaves, if at all.
+func child(mode, path string) {
+	s, err := suite.Load(path)
+	if err != nil {
+		panic(err)
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	enc := json.NewEncoder(os.Stdout)
+	for kk := 0; scanner.Scan(); kk++ {
+		var req request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			panic(err)
+		}
+		test := s.Tests[kk]
+		res := response{ID: req.ID, Transformed: test.Transformed, Rebased: test.Rebased}
+		switch mode {
+		case "id":
+			res.ID = "other"
+		case "wrong":
+			res.Transformed = test.Rebased
+			res.Rebased = test.Transformed
+		case "error":
+			res = response{ID: req.ID, Error: "not implemented"}
+		}
+		if err := enc.Encode(res); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func conform(t *testing.T, mode string) (string, error) {
+	path := filepath.Join(t.TempDir(), "small.json")
+	data := `{
+		"format": "compact",
+		"version": 1,
+		"tests": [
+			["ab", "", ["(a=)b"], ["a(b=)"], ["(b=)"], ["(a=)"]],
+			["ab", "xba", ["(=x)ab"], ["(a)b="], ["x(a)b="], ["(=x)ba"]]
+		],
+		"count": 2
+	}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "CONFORM_ARGS=-v -suite "+path+" "+os.Args[0]+" -child "+mode+" "+path)
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func TestConforming(t *testing.T) {
+	out, err := conform(t, "good")
+	if err != nil || !strings.Contains(out, "PASS small.json/1") || !strings.Contains(out, "2 passed, 0 failed") {
+		t.Error("Unexpected", err, out)
+	}
+}
+
+func TestFailing(t *testing.T) {
+	for _, mode := range []string{"wrong", "error"} {
+		out, err := conform(t, mode)
+		if err == nil || !strings.Contains(out, "FAIL small.json/1") || !strings.Contains(out, "0 passed, 2 failed") {
+			t.Error("Unexpected", mode, err, out)
+		}
+	}
+}
+
+func TestMismatchedID(t *testing.T) {
+	out, err := conform(t, "id")
+	if err == nil || !strings.Contains(out, `response for small.json/0 has id "other"`) || strings.Contains(out, "small.json/1") {
+		t.Error("Unexpected", err, out)
+	}
+}
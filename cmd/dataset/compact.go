@@ -0,0 +1,190 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+
+	"github.com/dotchain/dataset/tools/lib"
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+)
+
+// pairs enumerates pairs of operations in the compact form
+type pairs func(fn func(input, left, right string))
+
+func genSplices(w *lib.SuiteWriter, p params) {
+	x := &lib.Splices{Input: p.Input, Inserts: p.Inserts}
+	writeCompact(w, p, func(fn func(input, left, right string)) {
+		x.ForEachUniquePair(p.letters(), fn)
+	})
+}
+
+func genMoves(w *lib.SuiteWriter, p params) {
+	x := &lib.Moves{Input: p.Input}
+	writeCompact(w, p, func(fn func(input, left, right string)) {
+		x.ForEachUniquePair(p.letters(), fn)
+	})
+}
+
+func genSpliceMoves(w *lib.SuiteWriter, p params) {
+	x := &lib.Splices{Input: p.Input, Inserts: p.Inserts}
+	writeCompact(w, p, func(fn func(input, left, right string)) {
+		x.ForEachUniqueSpliceMovePair(p.letters(), fn)
+	})
+}
+
+// genRanges uses the input as the compact form of an array of
+// strings such as "[ab][ab]"
+func genRanges(w *lib.SuiteWriter, p params) {
+	x := &lib.Ranges{Input: stringArray("ranges", p.Input), Inserts: p.Inserts}
+	writeCompact(w, p, func(fn func(input, left, right string)) {
+		if err := x.ForEachUniquePair(p.letters(), fn); err != nil {
+			log.Panic(err)
+		}
+	})
+}
+
+// genSets uses the input as the compact form of a map of strings
+// such as "{a:x,b:y}" and the inserts as the new values
+func genSets(w *lib.SuiteWriter, p params) {
+	x := &lib.Sets{Input: stringMap("sets", p.Input), Keys: p.Keys, Values: p.Inserts}
+	for _, value := range x.Values {
+		if value == "" {
+			log.Panicf("sets: values cannot be empty")
+		}
+	}
+
+	writeCompact(w, p, func(fn func(input, left, right string)) {
+		if err := x.ForEachUniquePair(p.letters(), fn); err != nil {
+			log.Panic(err)
+		}
+	})
+}
+
+// genSequences pairs sequences of splices and moves of the input with
+// the lengths from the params
+func genSequences(w *lib.SuiteWriter, p params) {
+	x := &lib.Sequences{Input: p.Input, Inserts: p.Inserts, Lengths: p.Lengths}
+	writeSequences(w, p, func(fn func(input string, left, right []string)) {
+		if err := x.ForEachUniquePair(p.letters(), fn); err != nil {
+			log.Panic(err)
+		}
+	})
+}
+
+// stringArray decodes the compact form of an array of strings
+func stringArray(family, input string) []string {
+	v, err := lib.Compact{}.DecodeValueE(input)
+	elems, ok := v.(types.A)
+	if err != nil || !ok {
+		log.Panicf("%s: input %q is not an array", family, input)
+	}
+	result := []string{}
+	for _, elem := range elems {
+		str, ok := elem.(types.S16)
+		if !ok {
+			log.Panicf("%s: input %q is not an array of strings", family, input)
+		}
+		result = append(result, string(str))
+	}
+	return result
+}
+
+// stringMap decodes the compact form of a map of strings
+func stringMap(family, input string) map[string]string {
+	v, err := lib.Compact{}.DecodeValueE(input)
+	m, ok := v.(types.M)
+	if err != nil || !ok {
+		log.Panicf("%s: input %q is not a map", family, input)
+	}
+	result := map[string]string{}
+	for key, value := range m {
+		str, ok := value.(types.S16)
+		if !ok {
+			log.Panicf("%s: input %q is not a map of strings", family, input)
+		}
+		result[key.(string)] = string(str)
+	}
+	return result
+}
+
+// writeCompact merges every pair and writes the compact suite.  The
+// changes are added as a final column in expanded suites.
+func writeCompact(w *lib.SuiteWriter, p params, forEach pairs) {
+	compact := lib.Compact{}
+	forEach(func(input, left, right string) {
+		inputl, l := compact.Decode(left)
+		inputr, r := compact.Decode(right)
+		if inputl != inputr || input != inputl {
+			log.Panic("Invalid inputs", inputl, inputr, left, right)
+		}
+		writeTest(w, p, input, []string{left}, []string{right}, l, r)
+	})
+}
+
+// writeSequences is like writeCompact but each side is a sequence of
+// operations which is merged as a changes.ChangeSet
+func writeSequences(w *lib.SuiteWriter, p params, forEach func(fn func(input string, left, right []string))) {
+	compact := lib.Compact{}
+	forEach(func(input string, left, right []string) {
+		inputl, l, errl := compact.DecodeSeq(left)
+		inputr, r, errr := compact.DecodeSeq(right)
+		if errl != nil || errr != nil || inputl != inputr || input != inputl {
+			log.Panic("Invalid sequences", inputl, inputr, left, right, errl, errr)
+		}
+		writeTest(w, p, input, left, right, l, r)
+	})
+}
+
+// writeTest merges the left and right changes and writes the test
+func writeTest(w *lib.SuiteWriter, p params, input string, left, right []string, l, r changes.Change) {
+	compact := lib.Compact{}
+	mergedl, mergedr := changes.Merge(l, r)
+	allLeft := changes.ChangeSet{l, mergedl}
+	allRight := changes.ChangeSet{r, mergedr}
+
+	outputl := compact.Apply(input, allLeft)
+	outputr := compact.Apply(input, allRight)
+	encodedl := append([]string{}, compact.Encode(compact.Apply(input, l), mergedl)...)
+	encodedr := append([]string{}, compact.Encode(compact.Apply(input, r), mergedr)...)
+	if outputl != outputr {
+		log.Panic("merge failure: ", input, "\n", left, " x ", right, "\n", encodedl, " x ", encodedr, "\n", outputl, " x ", outputr)
+	}
+
+	row := []interface{}{
+		input,
+		outputl,
+		left,
+		right,
+		encodedl,
+		encodedr,
+	}
+	if p.Expanded {
+		row = append(row, expand(l, r, mergedl, mergedr))
+	}
+	if err := w.Add(row); err != nil {
+		log.Panic(err)
+	}
+}
+
+// expand converts the changes of a test into the structured form
+func expand(left, right, transformed, rebased changes.Change) map[string]interface{} {
+	result := map[string]interface{}{}
+	columns := map[string]changes.Change{
+		"left":        left,
+		"right":       right,
+		"transformed": transformed,
+		"rebased":     rebased,
+	}
+	for key, c := range columns {
+		v, err := lib.Native{}.EncodeChange(c)
+		if err != nil {
+			log.Panic(err)
+		}
+		result[key] = v
+	}
+	return result
+}
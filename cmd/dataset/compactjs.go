@@ -0,0 +1,605 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package main
+
+// compactJS is the JavaScript decoder written along with the suites
+// by "dataset js".  It mirrors lib.Compact and must be kept in sync
+// with it.
+const compactJS = `// Decoder for the compact form of github.com/dotchain/dataset (see
+// CompactJSON.md).  It mirrors lib.Compact of the Go reference.
+//
+// Values are plain JSON: strings, arrays and objects with null for
+// changes.Nil.  Changes use the same objects as the expanded suites:
+//
+//   {Splice: {Offset, Before, After}}
+//   {Move: {Offset, Count, Distance}}
+//   {Replace: {Before, After}}
+//   {PathChange: {Path, Change}}
+//   {ChangeSet: [...]}
+//
+// As with types.S16 of dot, string offsets and counts are in UTF-16
+// code units which is also how JavaScript strings are indexed.
+
+const specials = "[{(=)}]+:,\\";
+
+// ParseError is thrown for malformed compact strings.  The offset is
+// in UTF-16 code units.
+export class ParseError extends Error {
+  constructor(input, offset, reason) {
+    super("compact: " + reason + " at offset " + offset + " of " + JSON.stringify(input));
+    this.input = input;
+    this.offset = offset;
+    this.reason = reason;
+  }
+}
+
+function fail(at, message) {
+  throw new ParseError(undefined, at, message);
+}
+
+// lex splits s into tokens, each of which is either a single code
+// point of text or one of the special characters
+function lex(s) {
+  const result = [];
+  for (let offset = 0; offset < s.length; ) {
+    const ch = s[offset];
+    if (ch === "\\") {
+      if (offset + 1 === s.length) {
+        fail(offset, "expected a character after \"\\\"");
+      }
+      const text = String.fromCodePoint(s.codePointAt(offset + 1));
+      result.push({ kind: "text", at: offset, text });
+      offset += 1 + text.length;
+    } else if (specials.indexOf(ch) >= 0) {
+      result.push({ kind: "punct", at: offset, text: ch });
+      offset++;
+    } else {
+      const text = String.fromCodePoint(s.codePointAt(offset));
+      result.push({ kind: "text", at: offset, text });
+      offset += text.length;
+    }
+  }
+  result.push({ kind: "eof", at: s.length, text: "" });
+  return result;
+}
+
+function isChange(n) {
+  switch (n.type) {
+    case "splice":
+    case "move":
+    case "range":
+    case "path":
+    case "set":
+      return true;
+    case "map":
+      return n.entries.some((e) => isChange(e.value));
+  }
+  return false;
+}
+
+class Parser {
+  constructor(s) {
+    this.tokens = lex(s);
+    this.pos = 0;
+  }
+
+  peek() {
+    return this.tokens[this.pos];
+  }
+
+  next() {
+    const t = this.tokens[this.pos];
+    if (t.kind !== "eof") {
+      this.pos++;
+    }
+    return t;
+  }
+
+  is(...puncts) {
+    const t = this.peek();
+    return t.kind === "punct" && puncts.indexOf(t.text) >= 0;
+  }
+
+  expect(punct) {
+    if (!this.is(punct)) {
+      fail(this.peek().at, "expected " + JSON.stringify(punct));
+    }
+    return this.next();
+  }
+
+  expr() {
+    return this.is("{") ? this.mapExpr() : this.seqExpr();
+  }
+
+  items() {
+    const s = { at: this.peek().at, text: "", elems: [], array: false };
+    for (;;) {
+      const t = this.peek();
+      if (t.kind === "text") {
+        this.next();
+        s.text += t.text;
+        s.elems.push({ type: "string", at: t.at, text: t.text });
+      } else if (this.is("[")) {
+        this.next();
+        const elem = this.expr();
+        if (isChange(elem)) {
+          fail(elem.at, "unexpected change within \"[]\"");
+        }
+        this.expect("]");
+        s.elems.push(elem);
+        s.array = true;
+      } else {
+        return s;
+      }
+    }
+  }
+
+  seqExpr() {
+    const left = this.items();
+    if (this.is("=")) {
+      const eq = this.next();
+      const skip = this.items();
+      if (!this.is("(")) {
+        fail(eq.at, "unexpected \"=\" outside of a change");
+      }
+      this.next();
+      const mid = this.items();
+      this.expect(")");
+      const right = this.items();
+      this.checkEnd();
+      const [l, s, m, r] = this.seqNodes(left, skip, mid, right);
+      return { type: "move", at: left.at, left: l, skip: s, mid: m, right: r, backward: true };
+    }
+    if (this.is("(")) {
+      this.next();
+      const n = this.paren(left);
+      this.checkEnd();
+      return n;
+    }
+    return this.seqNodes(left)[0];
+  }
+
+  paren(left) {
+    const start = this.pos;
+    const first = this.items();
+
+    if (this.is("=")) {
+      this.next();
+      const second = this.pos;
+      const after = this.items();
+      if (this.is(")")) {
+        this.next();
+        const right = this.items();
+        const [l, b, a, r] = this.seqNodes(left, first, after, right);
+        return { type: "splice", at: left.at, left: l, before: b, after: a, right: r };
+      }
+      if (!this.is("(", "=", "+", "{")) {
+        fail(this.peek().at, "unexpected " + JSON.stringify(this.peek().text));
+      }
+
+      this.pos = second;
+      if (first.elems.length > 0 && this.isOp()) {
+        const op = this.expr();
+        this.expect(")");
+        const right = this.items();
+        return {
+          type: "range",
+          at: left.at,
+          left: this.array(left),
+          elems: this.array(first),
+          right: this.array(right),
+          op,
+        };
+      }
+    } else if (this.is(")")) {
+      this.next();
+      const skip = this.items();
+      if (!this.is("=")) {
+        fail(first.at, "expected a change or \"=\"");
+      }
+      this.next();
+      const right = this.items();
+      const [l, m, s, r] = this.seqNodes(left, first, skip, right);
+      return { type: "move", at: left.at, left: l, skip: s, mid: m, right: r, backward: false };
+    }
+
+    this.pos = start;
+    const elem = this.expr();
+    this.expect(")");
+    if (!isChange(elem)) {
+      fail(elem.at, "expected a change or \"=\"");
+    }
+    const right = this.items();
+    return { type: "path", at: left.at, left: this.array(left), right: this.array(right), elem };
+  }
+
+  // isOp checks if the tokens up to the closing parenthesis form a
+  // change rather than a plain value
+  isOp() {
+    let hasOp = false;
+    let hasEqual = false;
+    let depth = 0;
+    for (const t of this.tokens.slice(this.pos)) {
+      const punct = t.kind === "punct" ? t.text : "";
+      if (t.kind === "eof" || (depth === 0 && punct === ")")) {
+        return hasOp && hasEqual;
+      } else if (punct === "=" || punct === "+") {
+        hasEqual = true;
+        hasOp = hasOp || (depth === 0 && punct === "+");
+      } else if (punct === "(" || punct === "[" || punct === "{") {
+        hasOp = hasOp || (depth === 0 && punct === "(");
+        depth++;
+      } else if (punct === ")" || punct === "]" || punct === "}") {
+        depth--;
+      }
+    }
+    return false;
+  }
+
+  checkEnd() {
+    if (this.is("(", "=")) {
+      const t = this.peek();
+      fail(t.at, "unexpected " + JSON.stringify(t.text) + " after a change");
+    }
+  }
+
+  seqNodes(...seqs) {
+    const array = seqs.some((s) => s.array);
+    return seqs.map((s) => (array ? this.array(s) : { type: "string", at: s.at, text: s.text }));
+  }
+
+  array(s) {
+    return { type: "array", at: s.at, elems: s.elems };
+  }
+
+  mapExpr() {
+    const m = this.mapNode();
+    if (this.is("+")) {
+      this.next();
+      if (!this.is("{")) {
+        fail(this.peek().at, "expected \"{\"");
+      }
+      const set = this.mapNode();
+      for (const n of [m, set]) {
+        if (isChange(n)) {
+          fail(n.at, "unexpected change within a set");
+        }
+      }
+      return { type: "set", at: m.at, map: m, changes: set };
+    }
+
+    if (this.peek().kind !== "eof" && !this.is(",", "}", ")", "]")) {
+      fail(this.peek().at, "expected \"+\"");
+    }
+
+    let seen = false;
+    for (const e of m.entries) {
+      if (isChange(e.value) && seen) {
+        fail(e.value.at, "unexpected second change");
+      }
+      seen = seen || isChange(e.value);
+    }
+    return m;
+  }
+
+  mapNode() {
+    const m = { type: "map", at: this.expect("{").at, entries: [] };
+    while (!this.is("}")) {
+      if (m.entries.length > 0) {
+        this.expect(",");
+      }
+      const e = { at: this.peek().at, key: "", value: null };
+      while (this.peek().kind === "text") {
+        e.key += this.next().text;
+      }
+      this.expect(":");
+      e.value = this.expr();
+      m.entries.push(e);
+    }
+    this.next();
+    return m;
+  }
+}
+
+function parse(s) {
+  try {
+    const p = new Parser(s);
+    const n = p.expr();
+    const t = p.peek();
+    if (t.kind !== "eof") {
+      fail(t.at, "unexpected " + JSON.stringify(t.text));
+    }
+    return n;
+  } catch (e) {
+    if (e instanceof ParseError) {
+      throw new ParseError(s, e.offset, e.reason);
+    }
+    throw e;
+  }
+}
+
+function count(v) {
+  return v.length;
+}
+
+function concat(...parts) {
+  if (typeof parts[0] === "string") {
+    return parts.join("");
+  }
+  return [].concat(...parts);
+}
+
+function pathChange(key, ch) {
+  if (ch && ch.PathChange) {
+    return { PathChange: { Path: [key].concat(ch.PathChange.Path), Change: ch.PathChange.Change } };
+  }
+  return { PathChange: { Path: [key], Change: ch } };
+}
+
+function value(n) {
+  return change(n)[0];
+}
+
+// change converts a node into the value it applies to and the change
+function change(n) {
+  switch (n.type) {
+    case "string":
+      return [n.text, null];
+    case "array":
+      return [n.elems.map(value), null];
+    case "map": {
+      const result = {};
+      let ch = null;
+      for (const e of n.entries) {
+        const [v, inner] = change(e.value);
+        result[e.key] = v;
+        if (inner !== null) {
+          ch = pathChange(e.key, inner);
+        }
+      }
+      return [result, ch];
+    }
+    case "splice": {
+      const [l, b, a, r] = [n.left, n.before, n.after, n.right].map(value);
+      return [concat(l, b, r), { Splice: { Offset: count(l), Before: b, After: a } }];
+    }
+    case "move": {
+      const [l, s, m, r] = [n.left, n.skip, n.mid, n.right].map(value);
+      if (n.backward) {
+        return [concat(l, s, m, r), { Move: { Offset: count(l) + count(s), Count: count(m), Distance: -count(s) } }];
+      }
+      return [concat(l, m, s, r), { Move: { Offset: count(l), Count: count(m), Distance: count(s) } }];
+    }
+    case "range": {
+      const op = change(n.op)[1];
+      const [l, elems, r] = [n.left, n.elems, n.right].map(value);
+      return [concat(l, elems, r), { ChangeSet: elems.map((_, kk) => pathChange(count(l) + kk, op)) }];
+    }
+    case "path": {
+      const [elem, inner] = change(n.elem);
+      const [l, r] = [n.left, n.right].map(value);
+      return [concat(l, [elem], r), pathChange(count(l), inner)];
+    }
+    case "set": {
+      const input = value(n.map);
+      const cs = [];
+      for (const e of n.changes.entries) {
+        const before = Object.prototype.hasOwnProperty.call(input, e.key) ? input[e.key] : null;
+        const empty = e.value.type === "string" && e.value.text === "";
+        const after = empty ? null : value(e.value);
+        cs.push(pathChange(e.key, { Replace: { Before: before, After: after } }));
+      }
+      return [input, { ChangeSet: cs }];
+    }
+  }
+  throw new Error("compact: unknown node " + n.type);
+}
+
+// decode returns the input value and the change of a compact string
+export function decode(s) {
+  if (s === "") {
+    return { input: "", change: null };
+  }
+  const [input, ch] = change(parse(s));
+  return { input, change: ch };
+}
+
+// decodeValue returns the value of a compact string that has no change
+export function decodeValue(s) {
+  const { input, change } = decode(s);
+  if (change !== null) {
+    throw new Error("compact: unexpected change in " + JSON.stringify(s));
+  }
+  return input;
+}
+
+// decodeSeq decodes a sequence of compact strings where each applies
+// to the result of the previous one into the input value and a
+// single change (a ChangeSet if there are several).
+export function decodeSeq(seq) {
+  let input = null;
+  let current = null;
+  const changes = [];
+  seq.forEach((s, kk) => {
+    const d = decode(s);
+    if (kk === 0) {
+      input = current = d.input;
+    } else if (!equal(d.input, current)) {
+      throw new Error("compact: " + JSON.stringify(s) + " does not apply to the previous result");
+    }
+    changes.push(d.change);
+    current = apply(current, d.change);
+  });
+  if (changes.length === 0) {
+    return { input: "", change: null };
+  }
+  return { input, change: changes.length === 1 ? changes[0] : { ChangeSet: changes } };
+}
+
+// apply applies a change to a value as dot would
+export function apply(v, ch) {
+  if (ch === null || ch === undefined) {
+    return v;
+  }
+  if (ch.Splice) {
+    const { Offset, Before, After } = ch.Splice;
+    return concat(v.slice(0, Offset), After, v.slice(Offset + count(Before)));
+  }
+  if (ch.Move) {
+    const { Offset: o, Count: c, Distance: d } = ch.Move;
+    if (d >= 0) {
+      return concat(v.slice(0, o), v.slice(o + c, o + c + d), v.slice(o, o + c), v.slice(o + c + d));
+    }
+    return concat(v.slice(0, o + d), v.slice(o, o + c), v.slice(o + d, o), v.slice(o + c));
+  }
+  if (ch.Replace) {
+    return ch.Replace.After;
+  }
+  if (ch.ChangeSet) {
+    return ch.ChangeSet.reduce(apply, v);
+  }
+  if (ch.PathChange) {
+    const { Path, Change } = ch.PathChange;
+    if (Path.length === 0) {
+      return apply(v, Change);
+    }
+    const key = Path[0];
+    const rest = { PathChange: { Path: Path.slice(1), Change } };
+    if (Array.isArray(v)) {
+      const result = v.slice();
+      result[key] = apply(v[key], rest);
+      return result;
+    }
+    const result = Object.assign({}, v);
+    const has = Object.prototype.hasOwnProperty.call(v, key);
+    const updated = apply(has ? v[key] : null, rest);
+    if (updated === null) {
+      delete result[key];
+    } else {
+      result[key] = updated;
+    }
+    return result;
+  }
+  throw new Error("compact: unknown change " + JSON.stringify(ch));
+}
+
+// equal checks if two values are the same
+export function equal(a, b) {
+  if (a === b) {
+    return true;
+  }
+  if (typeof a !== "object" || typeof b !== "object" || a === null || b === null) {
+    return false;
+  }
+  if (Array.isArray(a) !== Array.isArray(b)) {
+    return false;
+  }
+  const keys = Object.keys(a);
+  if (keys.length !== Object.keys(b).length) {
+    return false;
+  }
+  return keys.every((key) => Object.prototype.hasOwnProperty.call(b, key) && equal(a[key], b[key]));
+}
+
+// flatten returns the changes in ch as a list, expanding change sets
+// (including those at a path) and dropping null changes.  This allows
+// comparing changes that are grouped differently, such as a single
+// change and a change set with just that change.
+function flatten(ch, result = []) {
+  if (ch === null || ch === undefined) {
+    return result;
+  }
+  if (ch.ChangeSet) {
+    ch.ChangeSet.forEach((c) => flatten(c, result));
+  } else if (ch.PathChange) {
+    const { Path, Change } = ch.PathChange;
+    for (const c of flatten(Change)) {
+      result.push(Path.length === 0 ? c : Path.reduceRight((inner, key) => pathChange(key, inner), c));
+    }
+  } else {
+    result.push(ch);
+  }
+  return result;
+}
+
+// verify checks a test of a compact suite against a merge function
+// that takes two changes and returns [transformed, rebased].  Both
+// sides must converge to the final value and the merged changes must
+// match the transformed and rebased columns.  It throws an error
+// describing the failure if any.
+export function verify(test, merge) {
+  const [input, final, left, right] = test;
+  const l = decodeSeq(left);
+  const r = decodeSeq(right);
+  const start = decodeValue(input);
+  const expected = decodeValue(final);
+  const [transformed, rebased] = merge(l.change, r.change);
+  const afterLeft = apply(apply(start, l.change), transformed);
+  const afterRight = apply(apply(start, r.change), rebased);
+  if (!equal(afterLeft, expected) || !equal(afterRight, expected)) {
+    throw new Error(
+      "compact: " + JSON.stringify(test) + " converged to " + JSON.stringify([afterLeft, afterRight])
+    );
+  }
+  const columns = [
+    ["transformed", transformed, test[4]],
+    ["rebased", rebased, test[5]],
+  ];
+  for (const [name, actual, column] of columns) {
+    if (!equal(flatten(actual), flatten(decodeSeq(column).change))) {
+      throw new Error("compact: " + JSON.stringify(test) + " " + name + " = " + JSON.stringify(actual));
+    }
+  }
+}
+`
+
+// compactDTS has the TypeScript typings of compactJS
+const compactDTS = `// Typings for compact.js
+
+/** A value: strings, arrays and objects with null for changes.Nil */
+export type Value = string | Value[] | { [key: string]: Value } | null;
+
+/** A dot change in the structured form of the expanded suites */
+export type Change =
+  | null
+  | { Splice: { Offset: number; Before: Value; After: Value } }
+  | { Move: { Offset: number; Count: number; Distance: number } }
+  | { Replace: { Before: Value; After: Value } }
+  | { PathChange: { Path: (number | string)[]; Change: Change } }
+  | { ChangeSet: Change[] };
+
+/** The structured changes of a test in an expanded suite */
+export interface Changes {
+  left: Change;
+  right: Change;
+  transformed: Change;
+  rebased: Change;
+}
+
+/** [input, final, left, right, transformed, rebased] with the changes in expanded suites */
+export type Test = [string, string, string[], string[], string[], string[], Changes?];
+
+/** The header of a suite */
+export interface SuiteHeader {
+  format: string;
+  version: number;
+  generator?: string;
+  params?: { [key: string]: unknown };
+  count: number;
+}
+
+export class ParseError extends Error {
+  input: string;
+  offset: number;
+  reason: string;
+}
+
+export function decode(s: string): { input: Value; change: Change };
+export function decodeValue(s: string): Value;
+export function decodeSeq(seq: string[]): { input: Value; change: Change };
+export function apply(v: Value, change: Change): Value;
+export function equal(a: Value, b: Value): boolean;
+export function verify(test: Test, merge: (left: Change, right: Change) => [Change, Change]): void;
+`
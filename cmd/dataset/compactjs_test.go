@@ -0,0 +1,277 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dotchain/dataset/suite"
+	"github.com/dotchain/dataset/tools/lib"
+	"github.com/dotchain/dot/changes"
+)
+
+// checkJS decodes all the strings with compact.js and verifies all the
+// tests, once with a merge returning the expected columns and once
+// with a merge returning nulls
+const checkJS = `import { readFileSync } from "fs";
+import { decode, decodeSeq, verify } from "./compact.js";
+
+const { strings, tests } = JSON.parse(readFileSync(process.argv[2], "utf8"));
+const attempt = (fn) => {
+  try {
+    fn();
+    return "";
+  } catch (e) {
+    return e.message;
+  }
+};
+const expected = (test) => () => [decodeSeq(test[4]).change, decodeSeq(test[5]).change];
+process.stdout.write(JSON.stringify({
+  decoded: strings.map(decode),
+  passed: tests.map((test) => attempt(() => verify(test, expected(test)))),
+  failed: tests.map((test) => attempt(() => verify(test, () => [null, null])) !== ""),
+}));
+`
+
+// golden reads testdata/compactjs.json, which has compact strings
+// covering every escape and every kind of node along with the values
+// and changes (in the native form) they decode to
+func golden(t *testing.T) [][3]interface{} {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "compactjs.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows [][3]interface{}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+// TestCompactJSGolden checks the golden strings decoded by
+// TestCompactJS on the Go side, so that a change to the Go encoder
+// which compact.js would not follow fails even without node: the
+// strings must decode to the recorded values, encode back to the same
+// strings and cover every escape and every kind of node.
+func TestCompactJSGolden(t *testing.T) {
+	c, n := lib.Compact{}, lib.Native{}
+	kinds, escaped := map[string]bool{}, map[rune]bool{}
+	for _, row := range golden(t) {
+		str := row[0].(string)
+		var v changes.Value
+		var ch changes.Change
+		var err error
+		if row[2] == nil {
+			v, err = c.DecodeValueE(str)
+		} else {
+			v, ch, err = c.DecodeE(str)
+		}
+		if err != nil {
+			t.Fatal(str, err)
+		}
+		input, err := n.EncodeValue(v)
+		if err != nil {
+			t.Fatal(str, err)
+		}
+		change, err := n.EncodeChange(ch)
+		if err != nil {
+			t.Fatal(str, err)
+		}
+		if !reflect.DeepEqual(roundTrip(t, input), row[1]) || !reflect.DeepEqual(roundTrip(t, change), row[2]) {
+			t.Error("Decode", str, input, change)
+		}
+
+		encoded := c.EncodeValue(v)
+		if ch != nil {
+			encoded = c.Encode1(encoded, ch)
+		}
+		if encoded != str {
+			t.Error("Encode", str, encoded)
+		}
+
+		node, err := lib.Parse(str)
+		if err != nil {
+			t.Fatal(str, err)
+		}
+		nodeKinds(node, kinds)
+		for runes, kk := []rune(str), 0; kk < len(runes)-1; kk++ {
+			if runes[kk] == '\\' {
+				kk++
+				escaped[runes[kk]] = true
+			}
+		}
+	}
+
+	for _, kind := range []string{"String", "Array", "Map", "Splice", "Move", "Move backward", "Range", "Path", "Set"} {
+		if !kinds[kind] {
+			t.Error("Missing", kind)
+		}
+	}
+	for _, special := range "[{(=)}]+:,\\" {
+		if !escaped[special] {
+			t.Errorf("Missing escaped %q", special)
+		}
+	}
+}
+
+// nodeKinds records the kinds of all the nodes of the syntax tree,
+// with forward and backward moves as separate kinds
+func nodeKinds(node lib.Node, kinds map[string]bool) {
+	var children []lib.Node
+	switch n := node.(type) {
+	case *lib.String:
+		kinds["String"] = true
+	case *lib.Array:
+		kinds["Array"] = true
+		children = n.Elems
+	case *lib.Map:
+		kinds["Map"] = true
+		for _, e := range n.Entries {
+			children = append(children, e.Value)
+		}
+	case *lib.Splice:
+		kinds["Splice"] = true
+		children = []lib.Node{n.Left, n.Before, n.After, n.Right}
+	case *lib.Move:
+		kinds["Move"] = kinds["Move"] || !n.Backward
+		kinds["Move backward"] = kinds["Move backward"] || n.Backward
+		children = []lib.Node{n.Left, n.Skip, n.Mid, n.Right}
+	case *lib.Range:
+		kinds["Range"] = true
+		children = []lib.Node{n.Left, n.Elems, n.Right, n.Op}
+	case *lib.Path:
+		kinds["Path"] = true
+		children = []lib.Node{n.Left, n.Elem, n.Right}
+	case *lib.Set:
+		kinds["Set"] = true
+		children = []lib.Node{n.Map, n.Changes}
+	}
+	for _, child := range children {
+		nodeKinds(child, kinds)
+	}
+}
+
+// TestCompactJS decodes the strings of all the suites and the golden
+// strings with compact.js and checks them against lib.Compact.  It
+// needs node and fails without it unless DATASET_SKIP_JS is set, in
+// which case only TestCompactJSGolden checks the strings.
+func TestCompactJS(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil && os.Getenv("DATASET_SKIP_JS") != "" {
+		t.Log("WARNING: compact.js is NOT verified as DATASET_SKIP_JS is set and node is not found")
+		t.Skip("node not found")
+	}
+	if err != nil {
+		t.Fatalf("node is needed to verify compact.js (set DATASET_SKIP_JS=1 to skip): %v", err)
+	}
+
+	strs, tests := []string{}, [][]interface{}{}
+	seen := map[string]bool{}
+	for _, row := range golden(t) {
+		strs = append(strs, row[0].(string))
+		seen[row[0].(string)] = true
+	}
+	for _, file := range suite.Files() {
+		s, err := suite.Load(file)
+		if err != nil {
+			t.Fatal(file, err)
+		}
+		for _, test := range s.Tests {
+			all := [][]string{{test.Input, test.Final}, test.Left, test.Right, test.Transformed, test.Rebased}
+			for _, seq := range all {
+				for _, str := range seq {
+					if !seen[str] {
+						seen[str] = true
+						strs = append(strs, str)
+					}
+				}
+			}
+			tests = append(tests, []interface{}{test.Input, test.Final, test.Left, test.Right, test.Transformed, test.Rebased})
+		}
+	}
+
+	dir := t.TempDir()
+	input, err := json.Marshal(map[string]interface{}{"strings": strs, "tests": tests})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"package.json": `{"type": "module"}`,
+		"compact.js":   compactJS,
+		"check.js":     checkJS,
+		"input.json":   string(input),
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := exec.Command(node, filepath.Join(dir, "check.js"), filepath.Join(dir, "input.json")).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result struct {
+		Decoded []struct{ Input, Change interface{} }
+		Passed  []string
+		Failed  []bool
+	}
+	if err := json.Unmarshal(out, &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Decoded) != len(strs) || len(result.Passed) != len(tests) || len(result.Failed) != len(tests) {
+		t.Fatal("Unexpected result counts", len(result.Decoded), len(result.Passed), len(result.Failed))
+	}
+
+	c, n := lib.Compact{}, lib.Native{}
+	for kk, str := range strs {
+		v, ch, err := c.DecodeE(str)
+		if err != nil {
+			// inputs and finals have no changes
+			v, err = c.DecodeValueE(str)
+		}
+		if err != nil {
+			t.Fatal(str, err)
+		}
+		input, err1 := n.EncodeValue(v)
+		change, err2 := n.EncodeChange(ch)
+		if err1 != nil || err2 != nil {
+			t.Fatal(str, err1, err2)
+		}
+		expected := struct{ Input, Change interface{} }{roundTrip(t, input), roundTrip(t, change)}
+		if !reflect.DeepEqual(result.Decoded[kk], expected) {
+			t.Error("Decode", str, result.Decoded[kk], expected)
+		}
+	}
+
+	for kk, test := range tests {
+		if result.Passed[kk] != "" {
+			t.Error("Unexpected failure", result.Passed[kk])
+		}
+		changed := len(test[4].([]string)) > 0 || len(test[5].([]string)) > 0
+		if result.Failed[kk] != changed {
+			t.Error("Unexpected null merge result", test)
+		}
+	}
+}
+
+// roundTrip converts v to the values used by encoding/json
+func roundTrip(t *testing.T, v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
@@ -0,0 +1,109 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dotchain/dataset/tools/lib"
+)
+
+// family is an enumerator of operations along with a descriptive
+// name such as "ranges" or "splices[1]"
+type family struct {
+	name string
+	lib.Enumerator
+}
+
+// families returns all the families of operations that apply to the
+// input: splices and moves for strings, ranges (along with splices
+// and moves of each element) for arrays of strings and sets (along
+// with splices and moves of each value) for maps of strings.
+//
+// A new family of operations only needs to be added here for genCross
+// to pair it with all the other families.
+func families(p params) []family {
+	forString := func(input string) []family {
+		return []family{
+			{"splices", &lib.Splices{Input: input, Inserts: p.Inserts}},
+			{"moves", &lib.Moves{Input: input}},
+		}
+	}
+	nested := func(name string, key interface{}, inner []family) []family {
+		for kk := range inner {
+			inner[kk].name += "[" + name + "]"
+			inner[kk].Enumerator = &lib.Nested{Input: p.Input, Key: key, Inner: inner[kk].Enumerator}
+		}
+		return inner
+	}
+
+	switch {
+	case strings.HasPrefix(p.Input, "["):
+		elems := stringArray("cross", p.Input)
+		result := []family{{"ranges", &lib.Ranges{Input: elems, Inserts: p.Inserts}}}
+		for kk, elem := range elems {
+			result = append(result, nested(strconv.Itoa(kk), kk, forString(elem))...)
+		}
+		return result
+	case strings.HasPrefix(p.Input, "{"):
+		m := stringMap("cross", p.Input)
+		values := []string{}
+		for _, insert := range p.Inserts {
+			if insert != "" {
+				values = append(values, insert)
+			}
+		}
+		result := []family{{"sets", &lib.Sets{Input: m, Keys: p.Keys, Values: values}}}
+		keys := []string{}
+		for key := range m {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			result = append(result, nested(key, key, forString(m[key]))...)
+		}
+		return result
+	}
+	return forString(p.Input)
+}
+
+// crossParams drops the keys unless the input is a map, as only the
+// sets family uses them
+func crossParams(p params) params {
+	if !strings.HasPrefix(p.Input, "{") {
+		p.Keys = nil
+	}
+	return p
+}
+
+// genCross pairs the operations of every family that applies to the
+// input with those of every other family (in both orders).  Pairs of
+// operations from the same family are left to the other suites.
+func genCross(w *lib.SuiteWriter, p params) {
+	all := families(p)
+	writeCompact(w, p, func(fn func(input, left, right string)) {
+		seen := map[string]bool{}
+		for _, l := range all {
+			for _, r := range all {
+				if l.name == r.name {
+					continue
+				}
+				err := lib.ForEachUniqueCrossPair(p.letters(), l, r, func(input, left, right string) {
+					key := left + "|||" + right
+					if !seen[key] {
+						seen[key] = true
+						fn(input, left, right)
+					}
+				})
+				if err != nil {
+					log.Panic(err)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,224 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dotchain/dataset/suite"
+	"github.com/dotchain/dataset/tools/lib"
+)
+
+// Note that the alphabet is deliberately unicode to make sure that
+// the tests work properly with this.
+const alphabet = "𝐀𝐁𝐂𝐃𝐄𝐅𝐆𝐇𝐈𝐉𝐊𝐋𝐌𝐍𝐎𝐏"
+
+// params are the parameters of a generator.  They are recorded in
+// the header of the generated suite except for the family, the
+// layout and the outputs: Expanded adds the structured changes to
+// compact suites, NDJSON writes newline delimited JSON and Failures
+// is the file where the triples suite lists the triples which do not
+// converge.
+type params struct {
+	Family   string   `json:"-"`
+	Input    string   `json:"input"`
+	Inserts  []string `json:"inserts,omitempty"`
+	Keys     []string `json:"keys,omitempty"`
+	Lengths  []int    `json:"lengths,omitempty"`
+	Alphabet string   `json:"alphabet"`
+	Expanded bool     `json:"-"`
+	NDJSON   bool     `json:"-"`
+	Failures string   `json:"-"`
+}
+
+func (p params) letters() []string {
+	return strings.Split(p.Alphabet, "")
+}
+
+// generator is a suite along with the default parameters.  The
+// inserts are nil if the suite has no splices, the keys are nil
+// unless the suite changes maps and the lengths are nil unless the
+// suite has sequences of operations.  Keyed suites have their tests
+// in an object keyed by name instead of an array.  Suites which only
+// use some of their params depending on the input drop the others
+// with used (if not nil) so that the header only records what
+// affects the tests.
+type generator struct {
+	input   string
+	inserts []string
+	keys    []string
+	lengths []int
+	format  string
+	indent  string
+	keyed   bool
+	gen     func(w *lib.SuiteWriter, p params)
+	used    func(p params) params
+}
+
+var generators = map[string]generator{
+	"splices":     {"abcdefg", []string{"", "xyz", "XYZ"}, nil, nil, "compact", "\t", false, genSplices, nil},
+	"moves":       {"abcdefgh", nil, nil, nil, "compact", "\t", false, genMoves, nil},
+	"splicemoves": {"abcdefgh", []string{"", "xyz"}, nil, nil, "compact", "\t", false, genSpliceMoves, nil},
+	"ranges":      {"[ab][ab][ab]", []string{"", "x"}, nil, nil, "compact", "\t", false, genRanges, nil},
+	"sets":        {"{a:x,b:y}", []string{"x", "y", "z"}, []string{"a", "b", "c"}, nil, "compact", "\t", false, genSets, nil},
+	"cross":       {"[ab][ab]", []string{"", "x"}, []string{"a", "b", "c"}, nil, "compact", "\t", false, genCross, crossParams},
+	"sequences":   {"ab", []string{""}, nil, []int{2, 3}, "compact", "\t", false, genSequences, nil},
+	"triples":     {"ab", []string{"", "x"}, nil, nil, "triples", "\t", false, genTriples, nil},
+	"journal":     {"abc", []string{"", "xy"}, nil, nil, "journal_suite", "    ", true, genJournal, nil},
+}
+
+// params returns the default parameters of the generator
+func (g generator) params(family string) params {
+	return params{Family: family, Input: g.input, Inserts: g.inserts, Keys: g.keys, Lengths: g.lengths, Alphabet: alphabet}
+}
+
+func gen(args []string) {
+	if len(args) == 0 {
+		usage()
+	}
+	g, ok := generators[args[0]]
+	if !ok {
+		usage()
+	}
+
+	flags := flag.NewFlagSet("gen "+args[0], flag.ExitOnError)
+	input := flags.String("input", g.input, "the input string the operations apply to")
+	inserts := flags.String("inserts", strings.Join(g.inserts, ","), "comma separated list of strings inserted by splices (or the values of sets)")
+	keys := flags.String("keys", strings.Join(g.keys, ","), "comma separated list of the keys changed by sets")
+	lengths := flags.String("lengths", joinInts(g.lengths), "comma separated list of the lengths of sequences")
+	alpha := flags.String("alphabet", alphabet, "the characters used to normalize the generated tests")
+	output := flags.String("o", "", "the output file (defaults to standard output)")
+	expanded := flags.Bool("expanded", false, "include the structured dot changes in compact suites")
+	ndjson := flags.Bool("ndjson", false, "write newline delimited JSON with one test per line")
+	failures := flags.String("failures", "", "the file listing the triples which do not converge")
+	if err := flags.Parse(args[1:]); err != nil || flags.NArg() > 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	// flags the suite has no default for are still recorded so
+	// that check rejects them
+	set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	p := g.params(args[0])
+	p.Input, p.Alphabet = *input, *alpha
+	p.Expanded, p.NDJSON, p.Failures = *expanded, *ndjson, *failures
+	if p.Inserts != nil || set["inserts"] {
+		p.Inserts = strings.Split(*inserts, ",")
+	}
+	if p.Keys != nil || set["keys"] {
+		p.Keys = strings.Split(*keys, ",")
+	}
+	if p.Lengths != nil || set["lengths"] {
+		p.Lengths = nil
+		for _, length := range strings.Split(*lengths, ",") {
+			n, err := strconv.Atoi(length)
+			if err != nil {
+				log.Fatalf("%s: invalid length %q", p.Family, length)
+			}
+			p.Lengths = append(p.Lengths, n)
+		}
+	}
+
+	g.write(*output, p)
+}
+
+// write generates the suite into the file at path or into the
+// standard output if path is empty.  Generators call log.Panic on
+// failure, in which case the file is left untouched.
+//
+// Only compact suites can be expanded or written as newline delimited
+// JSON.
+func (g generator) write(path string, p params) {
+	if err := g.check(p); err != nil {
+		log.Fatalf("%s: %v", p.Family, err)
+	}
+	if g.used != nil {
+		p = g.used(p)
+	}
+
+	format := g.format
+	if p.Expanded {
+		format = "expanded"
+	}
+	h := lib.SuiteHeader{
+		Format:    format,
+		Version:   suite.Version,
+		Generator: "dataset gen " + p.Family,
+		Params:    p,
+	}
+
+	var w *lib.SuiteWriter
+	var err error
+	switch {
+	case path == "" && p.NDJSON:
+		w = lib.NewNDJSONSuiteWriter(os.Stdout, h)
+	case path == "":
+		w = lib.NewSuiteWriter(os.Stdout, h, g.indent, g.keyed)
+	case p.NDJSON:
+		w, err = lib.CreateNDJSONSuite(path, h)
+	default:
+		w, err = lib.CreateSuite(path, h, g.indent, g.keyed)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			w.Abort()
+			panic(r)
+		}
+	}()
+
+	g.gen(w, p)
+	if err := w.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// check returns an error if the layout is not supported by the suite
+// or if the params have inserts, keys or lengths which the suite does
+// not use
+func (g generator) check(p params) error {
+	if p.Inserts != nil && g.inserts == nil {
+		return fmt.Errorf("%s suites have no inserts", p.Family)
+	}
+	if p.Keys != nil && g.keys == nil {
+		return fmt.Errorf("%s suites have no keys", p.Family)
+	}
+	if p.Lengths != nil && g.lengths == nil {
+		return fmt.Errorf("%s suites have no lengths", p.Family)
+	}
+	if p.Expanded && g.format != "compact" {
+		return fmt.Errorf("%s suites cannot be expanded", g.format)
+	}
+	if p.NDJSON && g.format != "compact" {
+		return fmt.Errorf("%s suites cannot be written as newline delimited JSON", g.format)
+	}
+	if p.Failures != "" && g.format != "triples" {
+		return fmt.Errorf("%s suites have no failures", g.format)
+	}
+	for _, length := range p.Lengths {
+		if length < 1 {
+			return fmt.Errorf("invalid length %d", length)
+		}
+	}
+	return nil
+}
+
+func joinInts(ints []int) string {
+	strs := make([]string, len(ints))
+	for kk, n := range ints {
+		strs[kk] = strconv.Itoa(n)
+	}
+	return strings.Join(strs, ",")
+}
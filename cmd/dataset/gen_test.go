@@ -0,0 +1,44 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestGeneratorCheck(t *testing.T) {
+	for family, g := range generators {
+		if err := g.check(g.params(family)); err != nil {
+			t.Error("Unexpected error", family, err)
+		}
+	}
+
+	invalid := map[string]func(p *params){
+		"moves":     func(p *params) { p.Inserts = []string{"x"} },
+		"splices":   func(p *params) { p.Keys = []string{"a"} },
+		"triples":   func(p *params) { p.Lengths = []int{2} },
+		"sequences": func(p *params) { p.Lengths = []int{0} },
+		"journal":   func(p *params) { p.Failures = "failures.json" },
+	}
+	for family, update := range invalid {
+		g := generators[family]
+		p := g.params(family)
+		update(&p)
+		if err := g.check(p); err == nil {
+			t.Error("Unexpected success", family, p)
+		}
+	}
+}
+
+func TestCrossParams(t *testing.T) {
+	g := generators["cross"]
+	if p := g.used(g.params("cross")); p.Keys != nil || len(p.Inserts) == 0 {
+		t.Error("Unexpected params for an array", p)
+	}
+
+	p := g.params("cross")
+	p.Input = "{a:ab,b:c}"
+	if p = g.used(p); len(p.Keys) == 0 || len(p.Inserts) == 0 {
+		t.Error("Unexpected params for a map", p)
+	}
+}
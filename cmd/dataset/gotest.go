@@ -0,0 +1,256 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dotchain/dataset/suite"
+	"github.com/dotchain/dataset/tools/lib"
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+)
+
+// gotest converts a compact suite into a Go test file with the tests
+// as a table of dot values and changes
+func gotest(args []string) {
+	flags := flag.NewFlagSet("gotest", flag.ExitOnError)
+	pkg := flags.String("package", "", "the package of the test file (defaults to the name of the output directory)")
+	name := flags.String("name", "", "the name of the table (defaults to the name of the suite file)")
+	output := flags.String("o", "", "the output file (defaults to standard output)")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	path := flags.Arg(0)
+	if *name == "" {
+		*name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if *pkg == "" {
+		*pkg = "main"
+		if *output != "" {
+			abs, err := filepath.Abs(*output)
+			if err != nil {
+				log.Fatal(err)
+			}
+			*pkg = identifier(filepath.Base(filepath.Dir(abs)), false)
+		}
+	}
+
+	s, err := suite.Load(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	src, err := goSource(s, *pkg, *name, filepath.ToSlash(path))
+	if err != nil {
+		log.Fatal(path, ": ", err)
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(src)
+	} else {
+		err = ioutil.WriteFile(*output, src, 0644)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// goSource returns the formatted source of the test file.  The
+// table has one entry per test with the compact form of every change
+// in a comment and the test function merges the changes with
+// changes.Merge, checks that both sides converge and that the merged
+// changes encode to the transformed and rebased columns.  Changes
+// can be represented in more than one way (such as a changes.Splice
+// or a changes.ChangeSet with just that splice), so the merged
+// changes are compared in the compact form and the test file depends
+// on the lib package of this repository.
+func goSource(s *suite.Suite, pkg, name, path string) ([]byte, error) {
+	var b bytes.Buffer
+	table, test := identifier(name, false), "Test"+identifier(name, true)
+	pkgs := map[string]bool{}
+
+	fmt.Fprintf(&b, "// %s has the tests of %s\n", table, path)
+	fmt.Fprintf(&b, "var %s = []struct {\n", table)
+	fmt.Fprintf(&b, "\tInput, Final changes.Value\n")
+	fmt.Fprintf(&b, "\tLeft, Right, Transformed, Rebased changes.Change\n")
+	fmt.Fprintf(&b, "}{\n")
+
+	c := lib.Compact{}
+	for kk, t := range s.Tests {
+		input, err := c.DecodeValueE(t.Input)
+		if err != nil {
+			return nil, fmt.Errorf("test %d: %v", kk, err)
+		}
+		final, err := c.DecodeValueE(t.Final)
+		if err != nil {
+			return nil, fmt.Errorf("test %d: %v", kk, err)
+		}
+
+		fmt.Fprintf(&b, "\t{\n")
+		fmt.Fprintf(&b, "\t\tInput: %s,\n", goLiteral(input, pkgs))
+		fmt.Fprintf(&b, "\t\tFinal: %s,\n", goLiteral(final, pkgs))
+		columns := []struct {
+			name string
+			seq  []string
+		}{
+			{"Left", t.Left},
+			{"Right", t.Right},
+			{"Transformed", t.Transformed},
+			{"Rebased", t.Rebased},
+		}
+		for _, col := range columns {
+			_, cs, err := c.DecodeSeq(col.seq)
+			if err != nil {
+				return nil, fmt.Errorf("test %d: %v", kk, err)
+			}
+			var ch changes.Change
+			if len(cs) == 1 {
+				ch = cs[0]
+			} else if len(cs) > 1 {
+				ch = cs
+			}
+			fmt.Fprintf(&b, "\t\t// %s\n", comment(strings.Join(col.seq, " ")))
+			fmt.Fprintf(&b, "\t\t%s: %s,\n", col.name, goLiteral(ch, pkgs))
+		}
+		fmt.Fprintf(&b, "\t},\n")
+	}
+	fmt.Fprintf(&b, "}\n\n")
+
+	fmt.Fprintf(&b, "func %s(t *testing.T) {\n", test)
+	fmt.Fprintf(&b, "\tc := lib.Compact{}\n")
+	fmt.Fprintf(&b, "\tfor kk, test := range %s {\n", table)
+	fmt.Fprintf(&b, "\t\ttransformed, rebased := changes.Merge(test.Left, test.Right)\n")
+	fmt.Fprintf(&b, "\t\tafterLeft := test.Input.Apply(nil, test.Left)\n")
+	fmt.Fprintf(&b, "\t\tafterRight := test.Input.Apply(nil, test.Right)\n")
+	fmt.Fprintf(&b, "\t\tleft := afterLeft.Apply(nil, transformed)\n")
+	fmt.Fprintf(&b, "\t\tright := afterRight.Apply(nil, rebased)\n")
+	fmt.Fprintf(&b, "\t\tif !reflect.DeepEqual(left, test.Final) || !reflect.DeepEqual(right, test.Final) {\n")
+	fmt.Fprintf(&b, "\t\t\tt.Errorf(\"%%d: expected %%v, got %%v and %%v\", kk, test.Final, left, right)\n")
+	fmt.Fprintf(&b, "\t\t\tcontinue\n")
+	fmt.Fprintf(&b, "\t\t}\n")
+	fmt.Fprintf(&b, "\t\tinputl, inputr := c.EncodeValue(afterLeft), c.EncodeValue(afterRight)\n")
+	fmt.Fprintf(&b, "\t\tif x, expected := c.Encode(inputl, transformed), c.Encode(inputl, test.Transformed); !reflect.DeepEqual(x, expected) {\n")
+	fmt.Fprintf(&b, "\t\t\tt.Errorf(\"%%d: transformed = %%q, expected %%q\", kk, x, expected)\n")
+	fmt.Fprintf(&b, "\t\t}\n")
+	fmt.Fprintf(&b, "\t\tif x, expected := c.Encode(inputr, rebased), c.Encode(inputr, test.Rebased); !reflect.DeepEqual(x, expected) {\n")
+	fmt.Fprintf(&b, "\t\t\tt.Errorf(\"%%d: rebased = %%q, expected %%q\", kk, x, expected)\n")
+	fmt.Fprintf(&b, "\t\t}\n\t}\n}\n")
+
+	// the types package is only needed if any of the literals
+	// has a value
+	imports := ""
+	if pkgs["types"] {
+		imports = "\t\"github.com/dotchain/dot/changes/types\"\n"
+	}
+
+	var header bytes.Buffer
+	fmt.Fprintf(&header, "// Code generated by \"dataset gotest\" from %s; DO NOT EDIT.\n\n", path)
+	fmt.Fprintf(&header, "package %s\n\n", pkg)
+	fmt.Fprintf(&header, "import (\n\t\"reflect\"\n\t\"testing\"\n\n\t\"github.com/dotchain/dataset/tools/lib\"\n\t\"github.com/dotchain/dot/changes\"\n%s)\n\n", imports)
+	return format.Source(append(header.Bytes(), b.Bytes()...))
+}
+
+// comment escapes the runes that cannot be written as is in a line
+// comment (such as newlines) the way Go would in a string literal
+func comment(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if unicode.IsPrint(r) {
+			b.WriteRune(r)
+		} else {
+			quoted := strconv.QuoteRune(r)
+			b.WriteString(quoted[1 : len(quoted)-1])
+		}
+	}
+	return b.String()
+}
+
+// goLiteral returns the Go expression for a dot value or change and
+// adds the packages it refers to into pkgs
+func goLiteral(v interface{}, pkgs map[string]bool) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case int:
+		return fmt.Sprint(v)
+	case string:
+		return fmt.Sprintf("%q", v)
+	case types.S16:
+		pkgs["types"] = true
+		return fmt.Sprintf("types.S16(%q)", string(v))
+	case types.A:
+		pkgs["types"] = true
+		return "types.A{" + goList(len(v), func(kk int) string { return goLiteral(v[kk], pkgs) }) + "}"
+	case types.M:
+		pkgs["types"] = true
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, goLiteral(key, pkgs)+": "+goLiteral(v[key], pkgs))
+		}
+		sort.Strings(keys)
+		return "types.M{" + strings.Join(keys, ", ") + "}"
+	case changes.Splice:
+		return fmt.Sprintf("changes.Splice{Offset: %d, Before: %s, After: %s}", v.Offset, goLiteral(v.Before, pkgs), goLiteral(v.After, pkgs))
+	case changes.Move:
+		return fmt.Sprintf("changes.Move{Offset: %d, Count: %d, Distance: %d}", v.Offset, v.Count, v.Distance)
+	case changes.Replace:
+		return fmt.Sprintf("changes.Replace{Before: %s, After: %s}", goLiteral(v.Before, pkgs), goLiteral(v.After, pkgs))
+	case changes.PathChange:
+		path := goList(len(v.Path), func(kk int) string { return goLiteral(v.Path[kk], pkgs) })
+		return fmt.Sprintf("changes.PathChange{Path: []interface{}{%s}, Change: %s}", path, goLiteral(v.Change, pkgs))
+	case changes.ChangeSet:
+		return "changes.ChangeSet{" + goList(len(v), func(kk int) string { return goLiteral(v[kk], pkgs) }) + "}"
+	}
+	if v == changes.Nil {
+		return "changes.Nil"
+	}
+	log.Panicf("cannot convert %#v to Go", v)
+	return ""
+}
+
+func goList(n int, fn func(kk int) string) string {
+	result := make([]string, n)
+	for kk := range result {
+		result[kk] = fn(kk)
+	}
+	return strings.Join(result, ", ")
+}
+
+// identifier converts a file name like "splice-moves" into a Go
+// identifier ("spliceMoves" or "SpliceMoves" if exported)
+func identifier(name string, exported bool) string {
+	result := []rune{}
+	upper := exported
+	for _, r := range name {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			upper = len(result) > 0 || exported
+		case upper:
+			result = append(result, unicode.ToUpper(r))
+			upper = false
+		case len(result) == 0:
+			result = append(result, unicode.ToLower(r))
+		default:
+			result = append(result, r)
+		}
+	}
+	if len(result) == 0 || unicode.IsDigit(result[0]) {
+		result = append([]rune("x"), result...)
+	}
+	return string(result)
+}
@@ -0,0 +1,56 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dotchain/dataset/suite"
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+)
+
+func TestGoSource(t *testing.T) {
+	s, err := suite.Read(strings.NewReader(`{
+		"format": "compact",
+		"version": 1,
+		"tests": [["a\nb", "a\nbc", ["a\nb(=c)"], [], [], ["a\nb(=c)"]]],
+		"count": 1
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := goSource(s, "example", "newlines", "newlines.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`// a\nb(=c)`,
+		`Input: types.S16("a\nb"),`,
+		`c.Encode(inputl, transformed), c.Encode(inputl, test.Transformed)`,
+		`c.Encode(inputr, rebased), c.Encode(inputr, test.Rebased)`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(string(src), e) {
+			t.Errorf("Missing %s in\n%s", e, src)
+		}
+	}
+}
+
+func TestGoLiteralPackages(t *testing.T) {
+	pkgs := map[string]bool{}
+	move := goLiteral(changes.Move{Offset: 1, Count: 2, Distance: 3}, pkgs)
+	if move != "changes.Move{Offset: 1, Count: 2, Distance: 3}" || pkgs["types"] {
+		t.Error("Unexpected", move, pkgs)
+	}
+
+	replace := goLiteral(changes.Replace{Before: changes.Nil, After: types.S16("x")}, pkgs)
+	if replace != `changes.Replace{Before: changes.Nil, After: types.S16("x")}` || !pkgs["types"] {
+		t.Error("Unexpected", replace, pkgs)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/dotchain/dataset/journal"
+	"github.com/dotchain/dataset/tools/lib"
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+)
+
+var seeds = []struct {
+	name    string
+	journal lib.Journal
+}{
+	{"simple1", lib.Journal{
+		entry("s0", "", "", "abc(=X)d"),
+		entry("s1", "s0", "", "a(b=Y)cXd"),
+		entry("c0", "", "", "a(=x)bcd"),
+		entry("c1", "", "c0", "axb(c=y)d"),
+	}},
+	{"simple2", lib.Journal{
+		entry("s0", "", "", "a(=x)bcd"),
+		entry("s1", "s0", "", "axb(c=y)d"),
+		entry("c0", "", "", "abc(=X)d"),
+		entry("c1", "", "c0", "a(b=Y)cXd"),
+	}},
+	{"simple3", lib.Journal{
+		entry("s0", "", "", "a(b=xy)c"),
+		entry("s1", "s0", "", "(ax=)yc"),
+		entry("c0", "", "", "a(b=XY)c"),
+		entry("c1", "", "c0", "aX(Yc=)"),
+	}},
+	{"staggered basis", lib.Journal{
+		entry("s0", "", "", "(=hello world)"),
+		entry("s1", "s0", "", "hello (=beautiful )world"),
+		entry("c0", "s0", "", "hello (=crazy )world"),
+		entry("x0", "s0", "", "hello world(=!)"),
+		entry("c1", "s1", "c0", "hello beautiful cra(z=)y world"),
+	}},
+}
+
+// genJournal writes a few hand-written journals followed by
+// journals built out of every unique pair of splices and moves.
+// Each such journal has the left op and a follow-up by the server
+// and the right op and a follow-up by a client which had not seen
+// any of the server ops:
+//
+//	["s0", "", "", left]
+//	["s1", "s0", "", server follow-up]
+//	["c0", "", "", right]
+//	["c1", "", "c0", client follow-up]
+//
+// The follow-ups replace the last character of the document (or
+// insert into an empty document).  A journal that cannot be rebased
+// is a bug in changes.Merge and fails the generation.
+func genJournal(w *lib.SuiteWriter, p params) {
+	write := func(name string, j lib.Journal) {
+		rebased, mergeChains, err := j.Rebase()
+		if err != nil {
+			log.Panic(name, ": ", err)
+		}
+
+		test := journal.Test{Journal: j, Rebased: rebased, MergeChains: mergeChains}
+		if err := w.AddNamed(name, test); err != nil {
+			log.Panic(err)
+		}
+	}
+
+	for _, seed := range seeds {
+		write(seed.name, seed.journal)
+	}
+
+	count := 0
+	journals := func(name string) func(input, left, right string) {
+		return func(input, left, right string) {
+			count++
+			write(fmt.Sprintf("%s %d", name, count), pairJournal(left, right))
+		}
+	}
+
+	splices := &lib.Splices{Input: p.Input, Inserts: p.Inserts}
+	splices.ForEachUniquePair(p.letters(), journals("splices"))
+
+	count = 0
+	moves := &lib.Moves{Input: p.Input}
+	moves.ForEachUniquePair(p.letters(), journals("moves"))
+}
+
+// pairJournal builds a journal with the left op on the server and the
+// right op on a client, each with a follow-up op
+func pairJournal(left, right string) lib.Journal {
+	return lib.Journal{
+		entry("s0", "", "", left),
+		entry("s1", "s0", "", followUp(left, "S")),
+		entry("c0", "", "", right),
+		entry("c1", "", "c0", followUp(right, "C")),
+	}
+}
+
+// followUp replaces the last character of the output of op with
+// insert
+func followUp(op, insert string) string {
+	compact := lib.Compact{}
+	v, ch, err := compact.DecodeE(op)
+	if err != nil {
+		log.Panic(err)
+	}
+	output, ok := v.Apply(nil, ch).(types.S16)
+	if !ok {
+		log.Panic(op, " does not apply to a string")
+	}
+
+	before := types.S16("")
+	if runes := []rune(string(output)); len(runes) > 0 {
+		before = types.S16(runes[len(runes)-1:])
+	}
+	splice := changes.Splice{
+		Offset: output.Count() - before.Count(),
+		Before: before,
+		After:  types.S16(insert),
+	}
+	encoded, err := compact.EncodeE(compact.EncodeValue(output), splice)
+	if err != nil {
+		log.Panic(err)
+	}
+	return encoded[0]
+}
+
+func entry(id, parent, basis, op string) lib.JournalEntry {
+	return lib.JournalEntry{ID: id, Parent: parent, Basis: basis, Op: op}
+}
@@ -0,0 +1,23 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestFollowUp(t *testing.T) {
+	tests := map[string]string{
+		"a(b=x)":         "a(x=S)",
+		"(ab=)":          "(=S)",
+		`a(=\()`:         `a(\(=S)`,
+		`a(=\\)`:         `a(\\=S)`,
+		`(a=x\\)\(`:      `x\\(\(=S)`,
+		"a(=\U0001D400)": "a(\U0001D400=S)",
+	}
+	for op, expected := range tests {
+		if actual := followUp(op, "S"); actual != expected {
+			t.Errorf("%s: expected %s, got %s", op, expected, actual)
+		}
+	}
+}
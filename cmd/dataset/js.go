@@ -0,0 +1,99 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dotchain/dataset/suite"
+	"github.com/dotchain/dataset/tools/lib"
+)
+
+// js converts compact suites into ES modules with TypeScript typings
+// and writes them along with the JavaScript decoder
+func js(args []string) {
+	flags := flag.NewFlagSet("js", flag.ExitOnError)
+	dir := flags.String("o", "js", "the output directory")
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		log.Fatal(err)
+	}
+	files := map[string]string{"compact.js": compactJS, "compact.d.ts": compactDTS}
+	for _, path := range flags.Args() {
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		if name == "compact" {
+			log.Fatalf("%s: the name conflicts with the decoder", path)
+		}
+		if _, ok := files[name+".js"]; ok {
+			log.Fatalf("%s: duplicate name %s", path, name)
+		}
+
+		s, err := suite.Load(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		module, err := jsModule(s, filepath.ToSlash(path))
+		if err != nil {
+			log.Fatal(path, ": ", err)
+		}
+		files[name+".js"], files[name+".d.ts"] = module, jsTypings
+	}
+
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(*dir, name), []byte(contents), 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// jsTypings are the typings of every module written by jsModule
+const jsTypings = `import type { SuiteHeader, Test } from "./compact.js";
+
+export declare const header: SuiteHeader;
+export declare const tests: Test[];
+export default tests;
+`
+
+// jsModule returns an ES module which exports the header and the
+// tests of the suite, one test per line
+func jsModule(s *suite.Suite, path string) (string, error) {
+	var params interface{}
+	if s.Params != nil {
+		params = s.Params
+	}
+	header, err := json.Marshal(struct {
+		lib.SuiteHeader
+		Count int `json:"count"`
+	}{lib.SuiteHeader{Format: s.Format, Version: s.Version, Generator: s.Generator, Params: params}, len(s.Tests)})
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by \"dataset js\" from %s; DO NOT EDIT.\n\n", path)
+	fmt.Fprintf(&b, "export const header = %s;\n\n", header)
+	fmt.Fprintf(&b, "export const tests = [\n")
+	for _, t := range s.Tests {
+		row, err := json.Marshal(t)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "  %s,\n", row)
+	}
+	fmt.Fprintf(&b, "];\n\nexport default tests;\n")
+	return b.String(), nil
+}
@@ -0,0 +1,120 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+// Command dataset generates the test suites of
+// github.com/dotchain/dataset.
+//
+// Usage:
+//
+//	dataset build [-manifest manifest.json]
+//	dataset gen splices|moves|splicemoves|ranges|sets|cross|sequences|triples|journal [flags]
+//	dataset validate [-schemas schema] [paths...]
+//	dataset gotest [-package name] [-name name] [-o file] suite.json
+//	dataset js [-o dir] suites...
+//
+// The build command generates all the suites described in the
+// manifest (please see manifest.json at the root of the repository
+// for an example).  Each suite specifies its family (one of the
+// generators), the input, inserts, keys, lengths and alphabet to use
+// and the output file relative to the manifest.
+//
+// The validate command checks all the suites in the provided files or
+// directories (defaulting to the json directory) against the JSON
+// schema for their format and checks that all the operations in
+// them decode and converge.
+//
+// The gotest command converts a compact suite into a Go test file
+// with a table of the tests as dot values and changes (along with the
+// compact form in comments) and a test that merges them with
+// changes.Merge and compares the encoded results with the
+// transformed and rebased columns.  This allows Go implementations
+// to use the suites without reading any JSON at runtime.  The results
+// are encoded with the lib package of this repository, which the
+// generated test imports.
+//
+// The js command converts compact suites into ES modules (with
+// TypeScript typings) exporting the header and the tests of each
+// suite.  It also writes compact.js, a decoder for the compact form
+// which mirrors lib.Compact, including the UTF-16 offsets of
+// types.S16.
+//
+// The gen command generates a single suite. The flags are:
+//
+//	-input string
+//	        the input string the operations apply to
+//	-inserts string
+//	        comma separated list of strings inserted by splices (or the values of sets)
+//	-keys string
+//	        comma separated list of the keys changed by sets
+//	-lengths string
+//	        comma separated list of the lengths of sequences
+//	-alphabet string
+//	        the characters used to normalize the generated tests
+//	-o string
+//	        the output file (defaults to standard output)
+//	-expanded
+//	        include the structured dot changes in compact suites
+//	-ndjson
+//	        write newline delimited JSON with one test per line
+//	-failures string
+//	        the file listing the triples which do not converge
+//
+// The defaults for input, inserts, keys and lengths depend on the
+// suite and match the bundled suites.  Inserts, keys and lengths are
+// rejected by the suites which do not use them.  The input of the
+// ranges suite is the compact form of an array of strings (such as
+// "[ab][ab][ab]") and that of the sets suite is the compact form of a
+// map (such as "{a:x,b:y}").  The cross suite pairs operations of different
+// families on the same input, which can be a string, an array of
+// strings (ranges along with splices and moves of an element) or a
+// map of strings (sets along with splices and moves of a value).  The
+// sequences suite pairs sequences of splices and moves (with the
+// provided lengths) which are merged as changes.ChangeSet.  The
+// triples suite merges every unique triple of splices and moves of
+// the input in all orders.  Triples which do not converge fail the
+// generator unless -failures names the file listing them along with
+// a reproduction of the divergence (see triples.Failure).  The
+// bundled moves suite can be generated with:
+//
+//	dataset gen moves -o json/compact/moves.json
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("dataset: ")
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "build":
+		build(os.Args[2:])
+	case "gen":
+		gen(os.Args[2:])
+	case "validate":
+		validate(os.Args[2:])
+	case "gotest":
+		gotest(os.Args[2:])
+	case "js":
+		js(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: dataset build [-manifest manifest.json]")
+	fmt.Fprintln(os.Stderr, "       dataset gen splices|moves|splicemoves|ranges|sets|cross|sequences|triples|journal [flags]")
+	fmt.Fprintln(os.Stderr, "       dataset validate [-schemas schema] [paths...]")
+	fmt.Fprintln(os.Stderr, "       dataset gotest [-package name] [-name name] [-o file] suite.json")
+	fmt.Fprintln(os.Stderr, "       dataset js [-o dir] suites...")
+	os.Exit(2)
+}
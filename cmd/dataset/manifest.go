@@ -0,0 +1,119 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// manifest describes all the suites built by "dataset build".  The
+// alphabet applies to all suites that do not specify their own.
+type manifest struct {
+	Alphabet string `json:"alphabet"`
+	Suites   []spec `json:"suites"`
+}
+
+// spec describes a single suite.  Family is one of the generators
+// and Output is relative to the manifest.  Input, inserts, keys and
+// lengths default to that of the generator if they are not
+// specified.  Expanded and NDJSON select the layout of the suite and
+// Failures is relative to the manifest too (see params).
+type spec struct {
+	Family   string    `json:"family"`
+	Input    *string   `json:"input"`
+	Inserts  *[]string `json:"inserts"`
+	Keys     *[]string `json:"keys"`
+	Lengths  *[]int    `json:"lengths"`
+	Alphabet string    `json:"alphabet"`
+	Output   string    `json:"output"`
+	Expanded bool      `json:"expanded"`
+	NDJSON   bool      `json:"ndjson"`
+	Failures string    `json:"failures"`
+}
+
+func build(args []string) {
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	path := flags.String("manifest", "manifest.json", "the manifest describing the suites")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	m, err := loadManifest(*path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dir := filepath.Dir(*path)
+	for _, s := range m.Suites {
+		g, p := s.params(m)
+		output := filepath.Join(dir, s.Output)
+		if p.Failures != "" {
+			p.Failures = filepath.Join(dir, p.Failures)
+		}
+		if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+			log.Fatal(err)
+		}
+		g.write(output, p)
+	}
+}
+
+func loadManifest(path string) (*manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var m manifest
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	for _, s := range m.Suites {
+		if _, ok := generators[s.Family]; !ok {
+			return nil, fmt.Errorf("%s: unknown family %q", path, s.Family)
+		}
+		if s.Output == "" {
+			return nil, fmt.Errorf("%s: missing output for %q", path, s.Family)
+		}
+		g, p := s.params(&m)
+		if err := g.check(p); err != nil {
+			return nil, fmt.Errorf("%s: %s: %v", path, s.Output, err)
+		}
+	}
+	return &m, nil
+}
+
+// params returns the generator of the suite and its parameters
+func (s spec) params(m *manifest) (generator, params) {
+	g := generators[s.Family]
+	p := g.params(s.Family)
+	if s.Input != nil {
+		p.Input = *s.Input
+	}
+	if s.Inserts != nil {
+		p.Inserts = *s.Inserts
+	}
+	if s.Keys != nil {
+		p.Keys = *s.Keys
+	}
+	if s.Lengths != nil {
+		p.Lengths = *s.Lengths
+	}
+	if s.Alphabet != "" {
+		p.Alphabet = s.Alphabet
+	} else if m.Alphabet != "" {
+		p.Alphabet = m.Alphabet
+	}
+	p.Expanded, p.NDJSON, p.Failures = s.Expanded, s.NDJSON, s.Failures
+	return g, p
+}
@@ -0,0 +1,78 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+
+	"github.com/dotchain/dataset/suite"
+	"github.com/dotchain/dataset/tools/lib"
+	"github.com/dotchain/dataset/triples"
+)
+
+// genTriples writes every unique triple of splices and moves along
+// with the operations applied when merging it in each order.
+//
+// Triples where the orders do not converge are not tests.  They are
+// written to the failures file of the params along with a
+// reproduction of the divergence (see triples.Failure) and fail the
+// build if there is no such file, so that they are never silently
+// dropped.
+func genTriples(w *lib.SuiteWriter, p params) {
+	var failures *lib.SuiteWriter
+	if p.Failures != "" {
+		h := lib.SuiteHeader{
+			Format:    "triples_failures",
+			Version:   suite.Version,
+			Generator: "dataset gen " + p.Family,
+			Params:    p,
+		}
+		var err error
+		if failures, err = lib.CreateSuite(p.Failures, h, "\t", false); err != nil {
+			log.Panic(err)
+		}
+		defer failures.Abort()
+	}
+
+	e := lib.Enumerators{
+		&lib.Splices{Input: p.Input, Inserts: p.Inserts},
+		&lib.Moves{Input: p.Input},
+	}
+	err := lib.ForEachUniqueTriple(p.letters(), e, func(input string, t lib.Triple) {
+		orders, outputs, err := t.Merge()
+		if err != nil {
+			log.Panic(err)
+		}
+
+		final := outputs[lib.TripleOrders[0]]
+		for _, order := range lib.TripleOrders {
+			if outputs[order] == final {
+				continue
+			}
+			failure, ok := triples.NewFailure(input, t, orders, outputs)
+			if failures == nil || !ok {
+				log.Panic(t, " ", order, " converges to ", outputs[order], " instead of ", final)
+			}
+			if err := failures.Add(failure); err != nil {
+				log.Panic(err)
+			}
+			return
+		}
+
+		test := triples.Test{Input: input, Final: final, Triple: t, Orders: orders}
+		if err := w.Add(test); err != nil {
+			log.Panic(err)
+		}
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if failures != nil {
+		if err := failures.Close(); err != nil {
+			log.Panic(err)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/dotchain/dataset/journal"
+	"github.com/dotchain/dataset/suite"
+	"github.com/dotchain/dataset/tools/lib"
+	"github.com/dotchain/dataset/triples"
+)
+
+// schemas maps the format of a suite to its schema file
+var schemas = map[string]string{
+	"compact":          "compact.schema.json",
+	"expanded":         "expanded.schema.json",
+	"journal_suite":    "journal_suite.schema.json",
+	"triples":          "triples.schema.json",
+	"triples_failures": "triples_failures.schema.json",
+}
+
+func validate(args []string) {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	dir := flags.String("schemas", "schema", "the directory with the JSON schemas")
+	if err := flags.Parse(args); err != nil {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"json"}
+	}
+
+	files := []string(nil)
+	for _, path := range paths {
+		err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+			ext := filepath.Ext(path)
+			if err == nil && !info.IsDir() && (ext == ".json" || ext == ".ndjson") {
+				files = append(files, path)
+			}
+			return err
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	sort.Strings(files)
+
+	failed := false
+	for _, file := range files {
+		for _, err := range validateFile(*dir, file) {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// validateFile checks the file against the schema for its format and
+// then checks every test in it.  Newline delimited JSON files are
+// always compact (or expanded) suites and their structure is checked
+// by the loader instead of the schema.
+func validateFile(dir, path string) []error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return []error{err}
+	}
+	if filepath.Ext(path) == ".ndjson" {
+		return validateCompact(data)
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return []error{err}
+	}
+	obj, _ := v.(map[string]interface{})
+	format, _ := obj["format"].(string)
+	name, ok := schemas[format]
+	if !ok {
+		return []error{fmt.Errorf("unknown format %q", format)}
+	}
+
+	schemaData, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return []error{err}
+	}
+	schema, err := lib.ParseSchema(schemaData)
+	if err != nil {
+		return []error{err}
+	}
+	if err := schema.Validate(v); err != nil {
+		return []error{err}
+	}
+
+	errs := []error(nil)
+	switch format {
+	case "compact", "expanded":
+		errs = validateCompact(data)
+	case "journal_suite":
+		s, err := journal.Read(bytes.NewReader(data))
+		if err != nil {
+			return []error{err}
+		}
+		names := make([]string, 0, len(s.Tests))
+		for name := range s.Tests {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := s.Tests[name].Validate(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", name, err))
+			}
+		}
+	case "triples":
+		s, err := triples.Read(bytes.NewReader(data))
+		if err != nil {
+			return []error{err}
+		}
+		for kk, test := range s.Tests {
+			if err := test.Validate(); err != nil {
+				errs = append(errs, fmt.Errorf("test %d: %v", kk, err))
+			}
+		}
+	case "triples_failures":
+		f, err := triples.ReadFailures(bytes.NewReader(data))
+		if err != nil {
+			return []error{err}
+		}
+		for kk, failure := range f.Tests {
+			if err := failure.Validate(); err != nil {
+				errs = append(errs, fmt.Errorf("failure %d: %v", kk, err))
+			}
+		}
+	}
+	return errs
+}
+
+func validateCompact(data []byte) []error {
+	s, err := suite.Read(bytes.NewReader(data))
+	if err != nil {
+		return []error{err}
+	}
+
+	errs := []error(nil)
+	for kk, test := range s.Tests {
+		if err := test.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("test %d: %v", kk, err))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,224 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+// Package journal loads the journal suite and runs it against a
+// merge implementation.
+//
+// Each test of the suite is a journal: the sequence of operations
+// received by a server from all its clients.  An entry of the
+// journal is [id, parent, basis, op] where parent is the last server
+// operation seen by the client, basis is the previous operation of
+// the same client and op is in the compact form applying to the
+// state of the client.
+//
+// The server transforms each operation against all the operations
+// the client had not seen and appends it to its stream (rebased).
+// The client in turn receives those unseen operations transformed
+// against its own operation (the merge chain of the operation).
+//
+// Any dot-compatible merge implementation can be checked against the
+// bundled suite with:
+//
+//	func TestJournal(t *testing.T) {
+//	        journal.Run(t, changes.Merge)
+//	}
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"testing"
+
+	"github.com/dotchain/dataset/suite"
+	"github.com/dotchain/dataset/tools/lib"
+)
+
+// Test is a single journal along with the expected rebased
+// operations and merge chains
+type Test struct {
+	Journal     lib.Journal `json:"journal"`
+	Rebased     []string    `json:"rebased"`
+	MergeChains [][]string  `json:"mergeChains"`
+}
+
+// Suite is a journal test suite. The tests are keyed by name.  The
+// header fields are the same as that of suite.Suite.
+type Suite struct {
+	Format    string          `json:"format"`
+	Version   int             `json:"version"`
+	Generator string          `json:"generator,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Tests     map[string]Test `json:"tests"`
+	Count     int             `json:"count"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It accepts the legacy
+// "test" key in place of "tests" and verifies the count if there is
+// one.
+func (s *Suite) UnmarshalJSON(data []byte) error {
+	type plain Suite
+	var v struct {
+		plain
+		Legacy map[string]Test `json:"test"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*s = Suite(v.plain)
+	switch {
+	case s.Tests != nil && v.Legacy != nil:
+		return fmt.Errorf("journal: both %q and %q present", "test", "tests")
+	case s.Tests == nil && v.Legacy == nil:
+		return fmt.Errorf("journal: no tests")
+	case s.Tests == nil:
+		s.Tests, s.Count = v.Legacy, len(v.Legacy)
+	case s.Count != len(s.Tests):
+		return fmt.Errorf("journal: expected %d tests, got %d", s.Count, len(s.Tests))
+	}
+	return nil
+}
+
+// Read reads a journal test suite
+func Read(r io.Reader) (*Suite, error) {
+	var s Suite
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, err
+	}
+	if s.Format != "journal_suite" {
+		return nil, fmt.Errorf("journal: unknown format %q", s.Format)
+	}
+	if s.Version > suite.Version {
+		return nil, fmt.Errorf("journal: unsupported version %d", s.Version)
+	}
+	return &s, nil
+}
+
+// Load reads a journal test suite from a file
+func Load(path string) (*Suite, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// File returns the path of the journal suite bundled with this
+// package
+func File() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "json", "journal_suite.json")
+}
+
+// Run runs the bundled journal suite against the provided merge
+// implementation
+func Run(t *testing.T, merge suite.MergeFunc) {
+	s, err := Load(File())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Run(t, merge)
+}
+
+// Run runs all the tests in the suite against the provided merge
+// implementation.  Each test is run as a subtest in the order of
+// their names.
+func (s *Suite) Run(t *testing.T, merge suite.MergeFunc) {
+	names := make([]string, 0, len(s.Tests))
+	for name := range s.Tests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		test := s.Tests[name]
+		t.Run(name, func(t *testing.T) {
+			if err := test.Verify(merge); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// Verify replays the journal with the provided merge implementation
+// and checks that the server stream and the merge chains match the
+// expected ones.  It also checks that the rebased operations form a
+// valid stream, each applying to the output of the previous one.
+func (t Test) Verify(merge suite.MergeFunc) (err error) {
+	if len(t.Rebased) != len(t.Journal) || len(t.MergeChains) != len(t.Journal) {
+		return fmt.Errorf("journal: expected %d rebased ops and merge chains", len(t.Journal))
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("journal: merge panicked: %v", r)
+		}
+	}()
+
+	rebased, mergeChains, err := t.Journal.RebaseWith(merge)
+	if err != nil {
+		return err
+	}
+
+	for kk, e := range t.Journal {
+		if rebased[kk] != t.Rebased[kk] {
+			return fmt.Errorf("journal: %s rebased = %q, expected %q", e.ID, rebased[kk], t.Rebased[kk])
+		}
+		if !t.equal(mergeChains[kk], t.MergeChains[kk]) {
+			return fmt.Errorf("journal: %s merge chain = %q, expected %q", e.ID, mergeChains[kk], t.MergeChains[kk])
+		}
+	}
+
+	stream := []string(nil)
+	for _, op := range rebased {
+		if op != "" {
+			stream = append(stream, op)
+		}
+	}
+	_, _, err = lib.Compact{}.DecodeSeq(stream)
+	return err
+}
+
+// Validate checks that the test is well formed without merging
+// anything: the ids are unique, parents and bases refer to earlier
+// entries and all the operations decode.
+func (t Test) Validate() error {
+	if len(t.Rebased) != len(t.Journal) || len(t.MergeChains) != len(t.Journal) {
+		return fmt.Errorf("journal: expected %d rebased ops and merge chains", len(t.Journal))
+	}
+
+	c := lib.Compact{}
+	seen := map[string]bool{"": true}
+	for kk, e := range t.Journal {
+		if seen[e.ID] {
+			return fmt.Errorf("journal: duplicate or empty id %q", e.ID)
+		}
+		if !seen[e.Parent] || !seen[e.Basis] {
+			return fmt.Errorf("journal: %s refers to an unknown entry", e.ID)
+		}
+		seen[e.ID] = true
+
+		ops := append([]string{e.Op, t.Rebased[kk]}, t.MergeChains[kk]...)
+		for _, op := range ops {
+			if op == "" {
+				continue
+			}
+			if _, _, err := c.DecodeE(op); err != nil {
+				return fmt.Errorf("journal: %s: %v", e.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (t Test) equal(actual, expected []string) bool {
+	return len(actual) == 0 && len(expected) == 0 || reflect.DeepEqual(actual, expected)
+}
@@ -0,0 +1,102 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package journal_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dotchain/dataset/journal"
+	"github.com/dotchain/dot/changes"
+)
+
+func TestDotMerge(t *testing.T) {
+	journal.Run(t, changes.Merge)
+}
+
+func TestVerify(t *testing.T) {
+	s, err := journal.Read(strings.NewReader(`{
+		"format": "journal_suite",
+		"test": {
+			"disjoint": {
+				"journal": [
+					["s0", "", "", "(=a)"],
+					["c0", "", "", "(=b)"]
+				],
+				"rebased": ["(=a)", "a(=b)"],
+				"mergeChains": [[], ["(=a)b"]]
+			}
+		}
+	}`))
+	if err != nil || len(s.Tests) != 1 {
+		t.Fatal("Unexpected", s, err)
+	}
+	test := s.Tests["disjoint"]
+
+	// merge places the left (server) insertion before the right one
+	merge := func(l, r changes.Change) (changes.Change, changes.Change) {
+		rs := r.(changes.Splice)
+		rs.Offset += l.(changes.Splice).After.Count()
+		return rs, l
+	}
+	if err := test.Verify(merge); err != nil {
+		t.Error("Unexpected error", err)
+	}
+
+	bad := func(l, r changes.Change) (changes.Change, changes.Change) {
+		return r, l
+	}
+	if err := test.Verify(bad); err == nil {
+		t.Error("Unexpected success")
+	}
+
+	panicky := func(l, r changes.Change) (changes.Change, changes.Change) {
+		panic("oops")
+	}
+	if err := test.Verify(panicky); err == nil {
+		t.Error("Unexpected success")
+	}
+
+	if _, err := journal.Read(strings.NewReader(`{"format": "compact"}`)); err == nil {
+		t.Error("Unexpected success")
+	}
+}
+
+func TestRead(t *testing.T) {
+	s, err := journal.Read(strings.NewReader(`{
+		"format": "journal_suite",
+		"version": 1,
+		"tests": {"empty": {"journal": [], "rebased": [], "mergeChains": []}},
+		"count": 1
+	}`))
+	if err != nil || len(s.Tests) != 1 {
+		t.Error("Unexpected", s, err)
+	}
+
+	_, err = journal.Read(strings.NewReader(`{"format": "journal_suite", "version": 1, "tests": {}, "count": 1}`))
+	if err == nil {
+		t.Error("Unexpected success")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]bool{
+		`{"journal": [["s0", "", "", "(=a)"], ["c0", "", "s0", "a(=b)"]], "rebased": ["(=a)", "a(=b)"], "mergeChains": [[], []]}`: true,
+		`{"journal": [["s0", "", "", "(=a)"], ["s0", "", "", "(=b)"]], "rebased": ["(=a)", "a(=b)"], "mergeChains": [[], []]}`:    false,
+		`{"journal": [["s0", "", "", "(=a)"], ["c0", "x", "", "(=b)"]], "rebased": ["(=a)", "a(=b)"], "mergeChains": [[], []]}`:   false,
+		`{"journal": [["s0", "", "", "(=a)"], ["c0", "", "", "(=b)"]], "rebased": ["(=a)", "a(=b"], "mergeChains": [[], []]}`:     false,
+		`{"journal": [["s0", "", "", "(=a)"]], "rebased": [], "mergeChains": [[]]}`:                                               false,
+	}
+	for data, valid := range tests {
+		var test journal.Test
+		if err := json.Unmarshal([]byte(data), &test); err != nil {
+			t.Fatal(err)
+		}
+		if err := test.Validate(); (err == nil) != valid {
+			t.Error("Unexpected", data, err)
+		}
+	}
+}
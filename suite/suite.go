@@ -0,0 +1,401 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+// Package suite loads the compact JSON test suites and runs them
+// against a merge implementation.
+//
+// Any dot-compatible merge implementation can be checked against all
+// the bundled suites with:
+//
+//	func TestMerge(t *testing.T) {
+//	        suite.Run(t, changes.Merge)
+//	}
+//
+// Please see github.com/dotchain/dataset/CompactJSON.md for the
+// format.
+package suite
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/dotchain/dataset/tools/lib"
+	"github.com/dotchain/dot/changes"
+)
+
+// MergeFunc merges two changes made on the same input.  It returns
+// the change to apply after left and the change to apply after right
+// (i.e. the transformed and rebased changes respectively). This is
+// the same as changes.Merge.
+type MergeFunc func(left, right changes.Change) (changes.Change, changes.Change)
+
+// Test is a single row of a compact test suite.  Changes is only
+// present in expanded suites.
+type Test struct {
+	Input, Final                      string
+	Left, Right, Transformed, Rebased []string
+	Changes                           *Changes
+}
+
+// Changes are the structured form of the changes of a test as
+// encoded by lib.Native.  Left and Right are the changes in the
+// compact columns while Transformed and Rebased are the merged
+// changes.  These are the values as decoded by encoding/json.
+type Changes struct {
+	Left        interface{} `json:"left"`
+	Right       interface{} `json:"right"`
+	Transformed interface{} `json:"transformed"`
+	Rebased     interface{} `json:"rebased"`
+}
+
+// Version is the latest version of the suite formats.  Suites
+// without a version (version 0) use "test" instead of "tests" and do
+// not have a count.
+const Version = 1
+
+// Suite is a compact test suite.  Generator and Params describe how
+// the suite was generated and Count is the number of tests.
+type Suite struct {
+	Format    string          `json:"format"`
+	Version   int             `json:"version"`
+	Generator string          `json:"generator,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Tests     []Test          `json:"tests"`
+	Count     int             `json:"count"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It accepts the legacy
+// "test" key in place of "tests" and verifies the count if there is
+// one.
+func (s *Suite) UnmarshalJSON(data []byte) error {
+	type plain Suite
+	var v struct {
+		plain
+		Legacy []Test `json:"test"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*s = Suite(v.plain)
+	switch {
+	case s.Tests != nil && v.Legacy != nil:
+		return fmt.Errorf("suite: both %q and %q present", "test", "tests")
+	case s.Tests == nil && v.Legacy == nil:
+		return fmt.Errorf("suite: no tests")
+	case s.Tests == nil:
+		s.Tests, s.Count = v.Legacy, len(v.Legacy)
+	case s.Count != len(s.Tests):
+		return fmt.Errorf("suite: expected %d tests, got %d", s.Count, len(s.Tests))
+	}
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Each row is encoded as
+// [input, final, left, right, transformed, rebased] with the changes
+// as a seventh column in expanded suites.
+func (t *Test) UnmarshalJSON(data []byte) error {
+	var row []json.RawMessage
+	if err := json.Unmarshal(data, &row); err != nil {
+		return err
+	}
+	if len(row) != 6 && len(row) != 7 {
+		return fmt.Errorf("suite: expected 6 or 7 columns, got %d", len(row))
+	}
+
+	fields := []interface{}{&t.Input, &t.Final, &t.Left, &t.Right, &t.Transformed, &t.Rebased, &t.Changes}
+	for kk := range row {
+		if err := json.Unmarshal(row[kk], fields[kk]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (t Test) MarshalJSON() ([]byte, error) {
+	row := []interface{}{t.Input, t.Final, t.Left, t.Right, t.Transformed, t.Rebased}
+	if t.Changes != nil {
+		row = append(row, t.Changes)
+	}
+	return json.Marshal(row)
+}
+
+// Read reads a compact test suite.  The suite can either be a single
+// JSON document or newline delimited JSON with the header on the
+// first line, one test per line and a final {"count": N} line.
+func Read(r io.Reader) (*Suite, error) {
+	dec := json.NewDecoder(r)
+	var header json.RawMessage
+	if err := dec.Decode(&header); err != nil {
+		return nil, err
+	}
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(header, &keys); err != nil {
+		return nil, err
+	}
+
+	var s Suite
+	_, tests := keys["tests"]
+	_, legacy := keys["test"]
+	if tests || legacy {
+		if err := json.Unmarshal(header, &s); err != nil {
+			return nil, err
+		}
+	} else if err := s.readNDJSON(header, dec); err != nil {
+		return nil, err
+	}
+
+	if s.Format != "compact" && s.Format != "expanded" {
+		return nil, fmt.Errorf("suite: unknown format %q", s.Format)
+	}
+	if s.Version > Version {
+		return nil, fmt.Errorf("suite: unsupported version %d", s.Version)
+	}
+	for kk, t := range s.Tests {
+		if expanded := s.Format == "expanded"; expanded != (t.Changes != nil) {
+			return nil, fmt.Errorf("suite: test %d: changes must be present only in expanded suites", kk)
+		}
+	}
+	return &s, nil
+}
+
+// readNDJSON reads the tests that follow the header.  The count must
+// be on the last line so that a truncated suite is not mistaken for
+// a complete one.
+func (s *Suite) readNDJSON(header json.RawMessage, dec *json.Decoder) error {
+	type plain Suite
+	if err := json.Unmarshal(header, (*plain)(s)); err != nil {
+		return err
+	}
+	s.Tests = []Test{}
+
+	for {
+		var line json.RawMessage
+		if err := dec.Decode(&line); err == io.EOF {
+			return fmt.Errorf("suite: missing count")
+		} else if err != nil {
+			return err
+		}
+
+		if line[0] == '[' {
+			var t Test
+			if err := json.Unmarshal(line, &t); err != nil {
+				return fmt.Errorf("suite: line %d: %v", len(s.Tests)+2, err)
+			}
+			s.Tests = append(s.Tests, t)
+			continue
+		}
+
+		var trailer struct {
+			Count *int `json:"count"`
+		}
+		if err := json.Unmarshal(line, &trailer); err != nil || trailer.Count == nil {
+			return fmt.Errorf("suite: line %d: expected a test or the count", len(s.Tests)+2)
+		}
+		if *trailer.Count != len(s.Tests) {
+			return fmt.Errorf("suite: expected %d tests, got %d", *trailer.Count, len(s.Tests))
+		}
+		s.Count = len(s.Tests)
+
+		if dec.More() {
+			return fmt.Errorf("suite: unexpected data after the count")
+		}
+		return nil
+	}
+}
+
+// Load reads a compact test suite from a file
+func Load(path string) (*Suite, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Files returns the paths of all the compact test suites bundled with
+// this package, including the newline delimited ones
+func Files() []string {
+	_, file, _, _ := runtime.Caller(0)
+	dir := filepath.Join(filepath.Dir(file), "..", "json", "compact")
+	files := []string(nil)
+	for _, pattern := range []string{"*.json", "*.ndjson"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			panic(err)
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Run runs all the bundled test suites against the provided merge
+// implementation.  Each suite is run as a subtest.
+func Run(t *testing.T, merge MergeFunc) {
+	files := Files()
+	if len(files) == 0 {
+		t.Fatal("suite: no test suites found")
+	}
+
+	for _, file := range files {
+		s, err := Load(file)
+		if err != nil {
+			t.Fatal(file, err)
+		}
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			s.Run(t, merge)
+		})
+	}
+}
+
+// Run runs all the tests in the suite against the provided merge
+// implementation. Each test is run as a subtest named by its index.
+func (s *Suite) Run(t *testing.T, merge MergeFunc) {
+	for kk, test := range s.Tests {
+		test := test
+		t.Run(strconv.Itoa(kk), func(t *testing.T) {
+			if err := test.Verify(merge); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// Verify decodes the left and right changes, merges them and checks
+// that both sides converge to the final value with the expected
+// transformed and rebased changes.
+func (t Test) Verify(merge MergeFunc) (err error) {
+	c := lib.Compact{}
+	left, err := t.decode(t.Input, t.Left)
+	if err != nil {
+		return err
+	}
+	right, err := t.decode(t.Input, t.Right)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("suite: %v panicked: %v", t, r)
+		}
+	}()
+
+	transformed, rebased := merge(left, right)
+	afterLeft, afterRight := c.Apply(t.Input, left), c.Apply(t.Input, right)
+	if final := c.Apply(afterLeft, transformed); final != t.Final {
+		return fmt.Errorf("suite: %v: left + transformed = %q", t, final)
+	}
+	if final := c.Apply(afterRight, rebased); final != t.Final {
+		return fmt.Errorf("suite: %v: right + rebased = %q", t, final)
+	}
+	if x := c.Encode(afterLeft, transformed); !t.equal(x, t.Transformed) {
+		return fmt.Errorf("suite: %v: transformed = %q", t, x)
+	}
+	if x := c.Encode(afterRight, rebased); !t.equal(x, t.Rebased) {
+		return fmt.Errorf("suite: %v: rebased = %q", t, x)
+	}
+	return nil
+}
+
+// Validate checks that the test is well formed without merging
+// anything: all the operations decode, each column applies to the
+// expected value and both sides converge to the final value.
+func (t Test) Validate() (err error) {
+	c := lib.Compact{}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("suite: %v: %v", t, r)
+		}
+	}()
+
+	for _, side := range [][2][]string{{t.Left, t.Transformed}, {t.Right, t.Rebased}} {
+		first, err := t.decode(t.Input, side[0])
+		if err != nil {
+			return err
+		}
+		after := c.Apply(t.Input, first)
+		second, err := t.decode(after, side[1])
+		if err != nil {
+			return err
+		}
+		if final := c.Apply(after, second); final != t.Final {
+			return fmt.Errorf("suite: %v: converges to %q", t, final)
+		}
+	}
+	if t.Changes != nil {
+		return t.validateChanges()
+	}
+	return nil
+}
+
+// validateChanges checks that the structured changes have the same
+// effect as the compact columns
+func (t Test) validateChanges() error {
+	c, n := lib.Compact{}, lib.Native{}
+	sides := [][2]interface{}{
+		{t.Changes.Left, t.Changes.Transformed},
+		{t.Changes.Right, t.Changes.Rebased},
+	}
+	for kk, seq := range [][]string{t.Left, t.Right} {
+		first, err := n.DecodeChange(sides[kk][0])
+		if err != nil {
+			return err
+		}
+		second, err := n.DecodeChange(sides[kk][1])
+		if err != nil {
+			return err
+		}
+		expected, _ := t.decode(t.Input, seq)
+		after := c.Apply(t.Input, first)
+		if after != c.Apply(t.Input, expected) {
+			return fmt.Errorf("suite: %v: changes differ from %q", t, seq)
+		}
+		if final := c.Apply(after, second); final != t.Final {
+			return fmt.Errorf("suite: %v: changes converge to %q", t, final)
+		}
+	}
+	return nil
+}
+
+// decode decodes a column into a single change if there is only one
+// and into a changes.ChangeSet otherwise
+func (t Test) decode(input string, seq []string) (changes.Change, error) {
+	start, cs, err := lib.Compact{}.DecodeSeq(seq)
+	if err != nil {
+		return nil, err
+	}
+	if len(cs) > 0 && start != input {
+		return nil, fmt.Errorf("suite: %v: %q does not apply to the input", t, seq[0])
+	}
+	if len(cs) == 1 {
+		return cs[0], nil
+	}
+	return cs, nil
+}
+
+func (t Test) equal(actual, expected []string) bool {
+	return len(actual) == 0 && len(expected) == 0 || reflect.DeepEqual(actual, expected)
+}
+
+// String returns the JSON form of the test
+func (t Test) String() string {
+	data, err := t.MarshalJSON()
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
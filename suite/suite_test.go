@@ -0,0 +1,146 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package suite_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dotchain/dataset/suite"
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+)
+
+func TestDotMerge(t *testing.T) {
+	suite.Run(t, changes.Merge)
+}
+
+func TestFiles(t *testing.T) {
+	ndjson := 0
+	for _, file := range suite.Files() {
+		s, err := suite.Load(file)
+		if err != nil || len(s.Tests) == 0 {
+			t.Error("Unexpected", file, err)
+			continue
+		}
+		if filepath.Ext(file) == ".ndjson" {
+			ndjson++
+			if s.Format != "compact" || s.Count != len(s.Tests) {
+				t.Error("Unexpected header", file, s.Format, s.Count)
+			}
+		}
+	}
+	if ndjson == 0 {
+		t.Error("No newline delimited suites in", suite.Files())
+	}
+}
+
+func TestVerify(t *testing.T) {
+	s, err := suite.Read(strings.NewReader(`{
+		"format": "compact",
+		"test": [["ab", "", ["(a=)b"], ["a(b=)"], ["(b=)"], ["(a=)"]]]
+	}`))
+	if err != nil || len(s.Tests) != 1 {
+		t.Fatal("Unexpected", s, err)
+	}
+
+	delA := changes.Splice{Offset: 0, Before: types.S16("a"), After: types.S16("")}
+	delB := changes.Splice{Offset: 0, Before: types.S16("b"), After: types.S16("")}
+	merge := func(l, r changes.Change) (changes.Change, changes.Change) {
+		return delB, delA
+	}
+	if err := s.Tests[0].Verify(merge); err != nil {
+		t.Error("Unexpected error", err)
+	}
+
+	bad := func(l, r changes.Change) (changes.Change, changes.Change) {
+		return nil, nil
+	}
+	if err := s.Tests[0].Verify(bad); err == nil {
+		t.Error("Unexpected success")
+	}
+
+	panicky := func(l, r changes.Change) (changes.Change, changes.Change) {
+		panic("oops")
+	}
+	if err := s.Tests[0].Verify(panicky); err == nil {
+		t.Error("Unexpected success")
+	}
+}
+
+func TestRead(t *testing.T) {
+	row := `["ab", "", ["(a=)b"], ["a(b=)"], ["(b=)"], ["(a=)"]]`
+	expanded := `["ab", "", ["(a=)b"], ["a(b=)"], ["(b=)"], ["(a=)"], {"left": null, "right": null, "transformed": null, "rebased": null}]`
+	tests := []struct {
+		json  string
+		count int
+	}{
+		{`{"format": "compact", "test": [` + row + `]}`, 1},
+		{`{"format": "compact", "version": 1, "tests": [` + row + `], "count": 1}`, 1},
+		{`{"format": "compact", "version": 1, "tests": [], "count": 0}`, 0},
+		{`{"format": "compact", "version": 1, "generator": "x", "params": {"input": "ab"}, "tests": [], "count": 0}`, 0},
+		{"{\"format\": \"compact\", \"version\": 1}\n" + row + "\n" + row + "\n{\"count\": 2}\n", 2},
+		{"{\"format\": \"compact\", \"version\": 1}\n{\"count\": 0}\n", 0},
+		{`{"format": "expanded", "version": 1, "tests": [` + expanded + `], "count": 1}`, 1},
+
+		// errors
+		{`{"format": "compact", "version": 1, "tests": [` + row + `], "count": 2}`, -1},
+		{`{"format": "compact", "version": 1, "test": [` + row + `], "tests": []}`, -1},
+		{`{"format": "compact", "version": 1}`, -1},
+		{`{"format": "compact", "version": 2, "tests": [], "count": 0}`, -1},
+		{`{"format": "journal_suite", "tests": [], "count": 0}`, -1},
+		{`{"format": "expanded", "version": 1, "tests": [` + row + `], "count": 1}`, -1},
+		{`{"format": "compact", "version": 1, "tests": [` + expanded + `], "count": 1}`, -1},
+		{"{\"format\": \"compact\", \"version\": 1}\n" + row + "\n", -1},
+		{"{\"format\": \"compact\", \"version\": 1}\n" + row + "\n{\"count\": 2}\n", -1},
+		{"{\"format\": \"compact\", \"version\": 1}\n{\"count\": 0}\n" + row + "\n", -1},
+		{"{\"format\": \"compact\", \"version\": 1}\n[\"ab\"]\n{\"count\": 1}\n", -1},
+		{"{\"format\": \"compact\", \"version\": 1}\n{\"other\": 1}\n", -1},
+	}
+
+	for _, test := range tests {
+		s, err := suite.Read(strings.NewReader(test.json))
+		switch {
+		case test.count < 0 && err == nil:
+			t.Error("Unexpected success", test.json)
+		case test.count >= 0 && err != nil:
+			t.Error("Unexpected error", test.json, err)
+		case err == nil && (len(s.Tests) != test.count || s.Count != test.count):
+			t.Error("Unexpected count", test.json, len(s.Tests), s.Count)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	native := `{
+		"left": {"Splice": {"Offset": 0, "Before": "a", "After": ""}},
+		"right": {"Splice": {"Offset": 1, "Before": "b", "After": ""}},
+		"transformed": {"Splice": {"Offset": 0, "Before": "b", "After": ""}},
+		"rebased": {"Splice": {"Offset": 0, "Before": "a", "After": ""}}
+	}`
+	tests := map[string]bool{
+		`["ab", "", ["(a=)b"], ["a(b=)"], ["(b=)"], ["(a=)"]]`:  true,
+		`["ab", "ab", [], [], [], []]`:                          true,
+		`["ab", "b", ["(a=)b"], ["a(b=)"], ["(b=)"], ["(a=)"]]`: false,
+		`["ab", "", ["(a=)b"], ["a(b=)"], ["(x=)"], ["(a=)"]]`:  false,
+		`["ab", "", ["(a=)b"], ["a(b=)"], ["(b="], ["(a=)"]]`:   false,
+		`["xy", "", ["(a=)b"], ["a(b=)"], ["(b=)"], ["(a=)"]]`:  false,
+
+		// expanded
+		`["ab", "", ["(a=)b"], ["a(b=)"], ["(b=)"], ["(a=)"], ` + native + `]`:                        true,
+		`["ab", "", ["(a=)b"], ["a(b=)"], ["(b=)"], ["(a=)"], {"left": null, "right": null}]`:         false,
+		`["ab", "", ["(a=)b"], ["a(b=)"], ["(b=)"], ["(a=)"], {"left": {"Move": {}}, "right": null}]`: false,
+	}
+	for row, valid := range tests {
+		var test suite.Test
+		if err := test.UnmarshalJSON([]byte(row)); err != nil {
+			t.Fatal(err)
+		}
+		if err := test.Validate(); (err == nil) != valid {
+			t.Error("Unexpected", row, err)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package lib
+
+// Node is implemented by all the nodes of the syntax tree of a
+// compact form string. Please see Parse and Print.
+//
+// String, Array and Map represent plain values while Splice, Move,
+// Range, Path and Set represent a value with a change embedded in
+// it. A Map can also have a change embedded in one of its values.
+type Node interface {
+	// Pos returns the byte offset of the node in the source
+	Pos() int
+}
+
+// String is a string value. Text is the actual (unescaped) text.
+type String struct {
+	At   int
+	Text string
+}
+
+// Array is an array value.  Each element is itself a value.
+type Array struct {
+	At    int
+	Elems []Node
+}
+
+// Map is a map value.  The value of at most one entry can have a
+// change embedded in it.
+type Map struct {
+	At      int
+	Entries []*Entry
+}
+
+// Entry is a single key value pair of a map
+type Entry struct {
+	At    int
+	Key   string
+	Value Node
+}
+
+// Splice is of the form Left(Before=After)Right.  All four values are
+// either *String or *Array.
+type Splice struct {
+	At                         int
+	Left, Before, After, Right Node
+}
+
+// Move is of the form Left=Skip(Mid)Right if Backward is set and
+// Left(Mid)Skip=Right otherwise. All four values are either *String
+// or *Array.
+type Move struct {
+	At                     int
+	Left, Skip, Mid, Right Node
+	Backward               bool
+}
+
+// Range is of the form Left(Elems=Op)Right where Op is applied to
+// every element of Elems.
+type Range struct {
+	At                 int
+	Left, Elems, Right *Array
+	Op                 Node
+}
+
+// Path is of the form Left(Elem)Right where Elem is an element of the
+// array with a change embedded in it.
+type Path struct {
+	At          int
+	Left, Right *Array
+	Elem        Node
+}
+
+// Set is of the form Map+Changes.  Entries of Changes with an empty
+// string value are deletions.
+type Set struct {
+	At           int
+	Map, Changes *Map
+}
+
+// Pos implements Node
+func (n *String) Pos() int { return n.At }
+
+// Pos implements Node
+func (n *Array) Pos() int { return n.At }
+
+// Pos implements Node
+func (n *Map) Pos() int { return n.At }
+
+// Pos implements Node
+func (n *Splice) Pos() int { return n.At }
+
+// Pos implements Node
+func (n *Move) Pos() int { return n.At }
+
+// Pos implements Node
+func (n *Range) Pos() int { return n.At }
+
+// Pos implements Node
+func (n *Path) Pos() int { return n.At }
+
+// Pos implements Node
+func (n *Set) Pos() int { return n.At }
+
+// IsChange checks if the node has a change embedded in it
+func IsChange(n Node) bool {
+	switch n := n.(type) {
+	case *Splice, *Move, *Range, *Path, *Set:
+		return true
+	case *Map:
+		for _, e := range n.Entries {
+			if IsChange(e.Value) {
+				return true
+			}
+		}
+	}
+	return false
+}
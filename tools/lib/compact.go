@@ -6,7 +6,9 @@ package lib
 
 import (
 	"encoding/json"
-	"strings"
+	"fmt"
+	"reflect"
+	"sort"
 
 	"github.com/dotchain/dot/changes"
 	"github.com/dotchain/dot/changes/types"
@@ -14,52 +16,330 @@ import (
 
 // Compact implements some helper routines for working with the
 // compact form as defined in CompactJSON.md
+//
+// The compact form is parsed into a syntax tree (see Parse) which is
+// then converted into dot values and changes.  Encoding works the
+// other way around, building a syntax tree and printing it (see
+// Print).
 type Compact struct{}
 
-var specials = "[{(=)}]+:,"
+var specials = "[{(=)}]+:,\\"
+
+// EncodeError is the error returned when a change or a value cannot
+// be represented in the compact form
+type EncodeError struct {
+	Value interface{}
+}
+
+// Error implements the error interface
+func (e *EncodeError) Error() string {
+	return fmt.Sprintf("compact: cannot encode %#v", e.Value)
+}
 
 // Decode takes a compact form string and converts it to a changes.Change
-func (Compact) Decode(s string) (string, changes.Change) {
+//
+// The returned input is the compact form of the value the change
+// applies to.  Strings are returned as is while arrays are returned
+// with every element enclosed in square brackets and maps are
+// returned with sorted keys.
+//
+// Decode panics if the string is malformed.  Use DecodeE to get an
+// error instead.
+func (c Compact) Decode(s string) (string, changes.Change) {
+	v, ch, err := c.DecodeE(s)
+	if err != nil {
+		panic(err)
+	}
+	return c.EncodeValue(v), ch
+}
+
+// DecodeE is like Decode except that it returns the input value
+// instead of its compact form and it returns a *ParseError if the
+// string is malformed
+func (c Compact) DecodeE(s string) (v changes.Value, ch changes.Change, err error) {
 	if s == "" {
-		return "", nil
+		return types.S16(""), nil, nil
+	}
+
+	n, err := Parse(s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer c.recover(s, &err)
+	if v, ch = c.change(n); ch == nil {
+		fail(0, "expected a change")
+	}
+	return v, ch, nil
+}
+
+// DecodeSeq decodes a sequence of compact form strings (such as the
+// transformed or rebased columns of a test suite) into a
+// changes.ChangeSet. This is the inverse of Encode.
+//
+// Each step must apply to the output of the previous step. The
+// returned input is the compact form of the value the first step
+// applies to and is empty if there are no steps.
+func (c Compact) DecodeSeq(seq []string) (string, changes.ChangeSet, error) {
+	input, output := "", ""
+	result := changes.ChangeSet(nil)
+	for kk, s := range seq {
+		v, ch, err := c.DecodeE(s)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if step := c.EncodeValue(v); kk == 0 {
+			input = step
+		} else if step != output {
+			return "", nil, fmt.Errorf("compact: step %d (%q) applies to %q instead of %q", kk, s, step, output)
+		}
+		output = c.Apply(c.EncodeValue(v), ch)
+		result = append(result, ch)
+	}
+	return input, result, nil
+}
+
+// recover converts a *ParseError or *EncodeError panic into an error
+func (c Compact) recover(input string, err *error) {
+	switch r := recover().(type) {
+	case nil:
+	case *ParseError:
+		r.Input = input
+		*err = r
+	case *EncodeError:
+		*err = r
+	default:
+		panic(r)
 	}
+}
+
+// change converts a node into the value it applies to and the
+// change.  The change is nil for plain values.
+func (c Compact) change(n Node) (changes.Value, changes.Change) {
+	switch n := n.(type) {
+	case *String:
+		return types.S16(n.Text), nil
+	case *Array:
+		result := types.A{}
+		for _, elem := range n.Elems {
+			result = append(result, c.value(elem))
+		}
+		return result, nil
+	case *Map:
+		result := types.M{}
+		var change changes.Change
+		for _, e := range n.Entries {
+			v, ch := c.change(e.Value)
+			result[e.Key] = v
+			if ch != nil {
+				change = c.pathChange(e.Key, ch)
+			}
+		}
+		return result, change
+	case *Splice:
+		l, b, a, r := c.value(n.Left), c.value(n.Before), c.value(n.After), c.value(n.Right)
+		return c.concat(n.At, l, b, r), c.splice(n.At, c.count(l), b, a)
+	case *Move:
+		l, s, m, r := c.value(n.Left), c.value(n.Skip), c.value(n.Mid), c.value(n.Right)
+		if n.Backward {
+			move := changes.Move{Offset: c.count(l) + c.count(s), Count: c.count(m), Distance: -c.count(s)}
+			return c.concat(n.At, l, s, m, r), move
+		}
+		move := changes.Move{Offset: c.count(l), Count: c.count(m), Distance: c.count(s)}
+		return c.concat(n.At, l, m, s, r), move
+	case *Range:
+		_, op := c.change(n.Op)
+		l, elems, r := c.value(n.Left), c.value(n.Elems), c.value(n.Right)
+		a, ok := elems.(types.A)
+		if !ok {
+			fail(n.At, "expected an array")
+		}
+		cs := changes.ChangeSet{}
+		for kk := range a {
+			cs = append(cs, c.pathChange(c.count(l)+kk, op))
+		}
+		return c.concat(n.At, l, elems, r), cs
+	case *Path:
+		elem, inner := c.change(n.Elem)
+		l, r := c.value(n.Left), c.value(n.Right)
+		return c.concat(n.At, l, types.A{elem}, r), c.pathChange(c.count(l), inner)
+	case *Set:
+		input, ok := c.value(n.Map).(types.M)
+		if !ok {
+			fail(n.At, "expected a map")
+		}
+		cs := changes.ChangeSet{}
+		for _, e := range n.Changes.Entries {
+			before, ok := input[e.Key]
+			if !ok {
+				before = changes.Nil
+			}
+			var after changes.Value = changes.Nil
+			if s, ok := e.Value.(*String); !ok || s.Text != "" {
+				after = c.value(e.Value)
+			}
+			replace := changes.Replace{Before: before, After: after}
+			cs = append(cs, c.pathChange(e.Key, replace))
+		}
+		return input, cs
+	}
+	panic(&EncodeError{n})
+}
+
+// value converts a node into the value it applies to
+func (c Compact) value(n Node) changes.Value {
+	v, _ := c.change(n)
+	return v
+}
+
+// pathChange creates a changes.PathChange, collapsing nested path
+// changes into a single one
+func (c Compact) pathChange(key interface{}, ch changes.Change) changes.Change {
+	path := []interface{}{key}
+	if p, ok := ch.(changes.PathChange); ok {
+		path, ch = append(path, p.Path...), p.Change
+	}
+	return changes.PathChange{Path: path, Change: ch}
+}
+
+// splice creates a changes.Splice of the node at the provided
+// position, failing if the values are not both strings or both
+// arrays
+func (c Compact) splice(at, offset int, before, after changes.Value) changes.Splice {
+	switch before := before.(type) {
+	case types.S16:
+		if after, ok := after.(types.S16); ok {
+			return changes.Splice{Offset: offset, Before: before, After: after}
+		}
+	case types.A:
+		if after, ok := after.(types.A); ok {
+			return changes.Splice{Offset: offset, Before: before, After: after}
+		}
+	}
+	fail(at, "cannot splice %s with %s", c.EncodeValue(before), c.EncodeValue(after))
+	return changes.Splice{}
+}
+
+// concat joins the parts of the node at the provided position,
+// failing if the parts are not all strings or all arrays
+func (c Compact) concat(at int, parts ...changes.Value) changes.Value {
+	if _, ok := parts[0].(types.S16); ok {
+		result := types.S16("")
+		for _, part := range parts {
+			s, ok := part.(types.S16)
+			if !ok {
+				fail(at, "cannot join a string with %s", c.EncodeValue(part))
+			}
+			result += s
+		}
+		return result
+	}
+
+	result := types.A{}
+	for _, part := range parts {
+		a, ok := part.(types.A)
+		if !ok {
+			fail(at, "cannot join an array with %s", c.EncodeValue(part))
+		}
+		result = append(result, a...)
+	}
+	return result
+}
+
+// DecodeValue converts the compact form of a value into a
+// changes.Value. Values within curly braces are treated as maps
+// (types.M), values with square brackets are treated as arrays
+// (types.A) and everything else as strings (types.S16).
+//
+// DecodeValue panics if the value is malformed.  Use DecodeValueE to
+// get an error instead.
+func (c Compact) DecodeValue(s string) changes.Value {
+	v, err := c.DecodeValueE(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// DecodeValueE is like DecodeValue except that it returns a
+// *ParseError if the value is malformed
+func (c Compact) DecodeValueE(s string) (v changes.Value, err error) {
+	n, err := Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	defer c.recover(s, &err)
+	if IsChange(n) {
+		fail(0, "unexpected change")
+	}
+	return c.value(n), nil
+}
+
+// EncodeValue converts a value into its compact form.  This is the
+// inverse of DecodeValue.
+func (c Compact) EncodeValue(v changes.Value) string {
+	return Print(c.node(v))
+}
 
-	l, r := strings.Index(s, "("), strings.LastIndex(s, ")")
-	left, mid, right := s[:l], s[l+1:r], s[r+1:]
-	if strings.Contains(mid, "=") {
-		e := strings.Index(mid, "=")
-		before, after := mid[:e], mid[e+1:]
-		offset := types.S16(left).Count()
-		input := left + before + right
-		splice := changes.Splice{
-			Offset: offset,
-			Before: types.S16(before),
-			After:  types.S16(after),
+// node converts a value into a syntax tree
+func (c Compact) node(v changes.Value) Node {
+	switch v := v.(type) {
+	case nil:
+		return &String{}
+	case types.S16:
+		return &String{Text: string(v)}
+	case types.A:
+		result := &Array{}
+		for _, elem := range v {
+			result.Elems = append(result.Elems, c.node(elem))
+		}
+		return result
+	case types.M:
+		result := &Map{}
+		for _, key := range c.sortedKeys(v) {
+			result.Entries = append(result.Entries, &Entry{Key: key, Value: c.node(v[key])})
 		}
-		return input, splice
+		return result
 	}
+	panic(&EncodeError{v})
+}
 
-	if strings.Contains(left, "=") {
-		parts := strings.Split(left, "=")
-		input := parts[0] + parts[1] + mid + right
-		offset := types.S16(parts[0] + parts[1]).Count()
-		distance := -types.S16(parts[1]).Count()
-		count := types.S16(mid).Count()
-		move := changes.Move{Offset: offset, Count: count, Distance: distance}
-		return input, move
+func (c Compact) sortedKeys(m types.M) []string {
+	keys := []string{}
+	for key := range m {
+		s, ok := key.(string)
+		if !ok {
+			panic(&EncodeError{m})
+		}
+		keys = append(keys, s)
 	}
+	sort.Strings(keys)
+	return keys
+}
 
-	if strings.Contains(right, "=") {
-		parts := strings.Split(right, "=")
-		input := left + mid + parts[0] + parts[1]
-		offset := types.S16(left).Count()
-		distance := types.S16(parts[0]).Count()
-		count := types.S16(mid).Count()
-		move := changes.Move{Offset: offset, Count: count, Distance: distance}
-		return input, move
+func (c Compact) count(v changes.Value) int {
+	switch v := v.(type) {
+	case types.S16:
+		return v.Count()
+	case types.A:
+		return v.Count()
 	}
+	panic(&EncodeError{v})
+}
 
-	panic("Unknown formatted string")
+func (c Compact) slice(v changes.Value, offset, count int) changes.Value {
+	if !c.inBounds(v, offset, count) {
+		panic(&EncodeError{v})
+	}
+	switch v := v.(type) {
+	case types.S16:
+		return v.Slice(offset, count).(types.S16)
+	case types.A:
+		return v.Slice(offset, count).(types.A)
+	}
+	panic(&EncodeError{v})
 }
 
 // Stringify converts a string-like value to string
@@ -77,16 +357,17 @@ func (c Compact) Stringify(x interface{}) string {
 	return ret
 }
 
-// Apply takes a single change and applies to the input string
+// Apply takes a single change and applies to the input
 func (c Compact) Apply(input string, ch changes.Change) string {
-	return string(types.S16(input).Apply(nil, ch).(types.S16))
+	return c.EncodeValue(c.DecodeValue(input).Apply(nil, ch))
 }
 
 // Encode takes an input and a set of changes and converts it into the
 // compact form
 func (c Compact) Encode(input string, ch changes.Change) []string {
 	result := []string(nil)
-	if cs, ok := ch.(changes.ChangeSet); ok {
+	v := c.DecodeValue(input)
+	if cs, ok := ch.(changes.ChangeSet); ok && !c.isRange(v, cs) && !c.isSet(v, cs) {
 		for _, cx := range cs {
 			result = append(result, c.Encode(input, cx)...)
 			input = c.Apply(input, cx)
@@ -104,39 +385,210 @@ func (c Compact) Encode(input string, ch changes.Change) []string {
 	return filtered
 }
 
-// Encode1 is like Encode but it only takes one change
+// EncodeE is like Encode except that it returns an error instead of
+// panicking when the input is malformed or the change cannot be
+// represented in the compact form
+func (c Compact) EncodeE(input string, ch changes.Change) (result []string, err error) {
+	defer c.recover(input, &err)
+	return c.Encode(input, ch), nil
+}
+
+// Encode1 is like Encode but it only takes one change.  A range
+// (i.e. a changes.ChangeSet of changes.PathChange on consecutive
+// elements of an array, all with the same inner change) and a set
+// (i.e. a changes.ChangeSet of changes.PathChange on keys of a map,
+// all with changes.Replace) are considered a single change.
 func (c Compact) Encode1(input string, ch changes.Change) string {
 	if ch == nil {
 		return ""
 	}
+	return Print(c.changeNode(c.DecodeValue(input), ch))
+}
 
-	u := types.S16(input)
+// changeNode converts a value and a change on it into a syntax tree
+func (c Compact) changeNode(u changes.Value, ch changes.Change) Node {
 	switch ch := ch.(type) {
 	case changes.Splice:
-		left := string(u.Slice(0, ch.Offset).(types.S16))
-		before := string(ch.Before.(types.S16))
-		after := string(ch.After.(types.S16))
+		if ch.Before == nil || ch.After == nil || !c.inBounds(u, ch.Offset, ch.Before.Count()) {
+			panic(&EncodeError{ch})
+		}
 		end := ch.Offset + ch.Before.Count()
-		right := string(u.Slice(end, u.Count()-end).(types.S16))
-		return left + "(" + before + "=" + after + ")" + right
+		return &Splice{
+			Left:   c.node(c.slice(u, 0, ch.Offset)),
+			Before: c.node(ch.Before),
+			After:  c.node(ch.After),
+			Right:  c.node(c.slice(u, end, c.count(u)-end)),
+		}
 	case changes.Move:
-		mid := string(u.Slice(ch.Offset, ch.Count).(types.S16))
-		left := string(u.Slice(0, ch.Offset).(types.S16))
+		if !c.inBounds(u, ch.Offset, ch.Count) || !c.inBounds(u, ch.Offset+ch.Distance, ch.Count) {
+			panic(&EncodeError{ch})
+		}
 		end := ch.Offset + ch.Count
-		len := u.Count()
-		right := string(u.Slice(end, len-end).(types.S16))
+		len := c.count(u)
+		mid := c.node(c.slice(u, ch.Offset, ch.Count))
 
 		if ch.Distance < 0 {
-			l1 := string(u.Slice(0, ch.Offset+ch.Distance).(types.S16))
-			l2 := string(u.Slice(ch.Offset+ch.Distance, -ch.Distance).(types.S16))
-			left = l1 + "=" + l2
-		} else {
-			r1 := string(u.Slice(end, ch.Distance).(types.S16))
-			r2 := string(u.Slice(end+ch.Distance, len-end-ch.Distance).(types.S16))
-			right = r1 + "=" + r2
+			return &Move{
+				Left:     c.node(c.slice(u, 0, ch.Offset+ch.Distance)),
+				Skip:     c.node(c.slice(u, ch.Offset+ch.Distance, -ch.Distance)),
+				Mid:      mid,
+				Right:    c.node(c.slice(u, end, len-end)),
+				Backward: true,
+			}
+		}
+
+		return &Move{
+			Left:  c.node(c.slice(u, 0, ch.Offset)),
+			Mid:   mid,
+			Skip:  c.node(c.slice(u, end, ch.Distance)),
+			Right: c.node(c.slice(u, end+ch.Distance, len-end-ch.Distance)),
+		}
+	case changes.PathChange:
+		if len(ch.Path) == 0 {
+			return c.changeNode(u, ch.Change)
+		}
+		return c.pathNode(u, ch)
+	case changes.ChangeSet:
+		if c.isRange(u, ch) {
+			return c.rangeNode(u.(types.A), ch)
+		}
+		if c.isSet(u, ch) {
+			return c.setNode(u.(types.M), ch)
 		}
+	}
+	panic(&EncodeError{ch})
+}
 
-		return left + "(" + mid + ")" + right
+// pathNode converts a change at a path. Changes to an element of an
+// array are represented by a *Path node while changes to a value in a
+// map are embedded in the *Map node
+func (c Compact) pathNode(u changes.Value, p changes.PathChange) Node {
+	rest := changes.PathChange{Path: p.Path[1:], Change: p.Change}
+	switch u := u.(type) {
+	case types.A:
+		idx, ok := p.Path[0].(int)
+		if !ok || idx < 0 || idx >= len(u) {
+			panic(&EncodeError{p})
+		}
+		return &Path{
+			Left:  c.node(u[:idx]).(*Array),
+			Elem:  c.changeNode(u[idx], rest),
+			Right: c.node(u[idx+1:]).(*Array),
+		}
+	case types.M:
+		key, ok := p.Path[0].(string)
+		if !ok {
+			panic(&EncodeError{p})
+		}
+		if _, ok := p.Change.(changes.Replace); ok && len(p.Path) == 1 {
+			return c.setNode(u, changes.ChangeSet{p})
+		}
+		if _, ok := u[key]; !ok {
+			panic(&EncodeError{p})
+		}
+
+		result := c.node(u).(*Map)
+		for _, e := range result.Entries {
+			if e.Key == p.Path[0] {
+				e.Value = c.changeNode(u[e.Key], rest)
+			}
+		}
+		return result
+	}
+	panic(&EncodeError{p})
+}
+
+// isRange checks if the change set can be encoded as a range
+// operation on the input
+func (c Compact) isRange(u changes.Value, cs changes.ChangeSet) bool {
+	if a, ok := u.(types.A); !ok || len(cs) == 0 || !c.inBounds(a, c.index(cs[0]), len(cs)) {
+		return false
+	}
+
+	var first changes.PathChange
+	for kk, cx := range cs {
+		p, ok := cx.(changes.PathChange)
+		if !ok || len(p.Path) != 1 || p.Change == nil {
+			return false
+		}
+		if kk == 0 {
+			first = p
+		}
+		if idx, ok := p.Path[0].(int); !ok || idx != first.Path[0].(int)+kk {
+			return false
+		}
+		if !reflect.DeepEqual(p.Change, first.Change) {
+			return false
+		}
+	}
+	return true
+}
+
+// index returns the index of a change to an element of an array or
+// -1 if the change is not a changes.PathChange on an index
+func (c Compact) index(ch changes.Change) int {
+	if p, ok := ch.(changes.PathChange); ok && len(p.Path) > 0 {
+		if idx, ok := p.Path[0].(int); ok {
+			return idx
+		}
+	}
+	return -1
+}
+
+// inBounds checks that count elements starting at offset are within
+// the value
+func (c Compact) inBounds(v changes.Value, offset, count int) bool {
+	return offset >= 0 && count >= 0 && offset+count <= c.count(v)
+}
+
+func (c Compact) rangeNode(u types.A, cs changes.ChangeSet) Node {
+	offset := cs[0].(changes.PathChange).Path[0].(int)
+	end := offset + len(cs)
+	return &Range{
+		Left:  c.node(u[:offset]).(*Array),
+		Elems: c.node(u[offset:end]).(*Array),
+		Right: c.node(u[end:]).(*Array),
+		Op:    c.changeNode(u[offset], cs[0].(changes.PathChange).Change),
+	}
+}
+
+// isSet checks if the change set can be encoded as a set operation
+// on the input
+func (c Compact) isSet(u changes.Value, cs changes.ChangeSet) bool {
+	if _, ok := u.(types.M); !ok || len(cs) == 0 {
+		return false
+	}
+
+	for _, cx := range cs {
+		p, ok := cx.(changes.PathChange)
+		if !ok || len(p.Path) != 1 {
+			return false
+		}
+		if _, ok := p.Path[0].(string); !ok {
+			return false
+		}
+		if _, ok := p.Change.(changes.Replace); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// setNode converts a set operation into a syntax tree.  An empty
+// value in the set stands for a deletion, so a change assigning an
+// empty string to a key cannot be encoded.
+func (c Compact) setNode(u types.M, cs changes.ChangeSet) Node {
+	set := &Map{}
+	for _, cx := range cs {
+		p := cx.(changes.PathChange)
+		after := p.Change.(changes.Replace).After
+		if after == types.S16("") {
+			panic(&EncodeError{p})
+		}
+		if after == changes.Nil {
+			after = nil
+		}
+		set.Entries = append(set.Entries, &Entry{Key: p.Path[0].(string), Value: c.node(after)})
 	}
-	panic(ch)
+	return &Set{Map: c.node(u).(*Map), Changes: set}
 }
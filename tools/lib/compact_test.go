@@ -0,0 +1,249 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package lib_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dotchain/dataset/tools/lib"
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+)
+
+func TestCompactRoundTrip(t *testing.T) {
+	tests := [][]string{
+		// encoded, input, output
+		{"hello (rob=roy)!", "hello rob!", "hello roy!"},
+		{"=Bad (Big )Wolf", "Bad Big Wolf", "Big Bad Wolf"},
+		{"(Bad )Big =Wolf", "Bad Big Wolf", "Big Bad Wolf"},
+		{"[h][e]([ll][lo]=(l=l )l)", "[h][e][ll][lo]", "[h][e][l l][l o]"},
+		{"[h]([e]=(=x)e)[ll]", "[h][e][ll]", "[h][xe][ll]"},
+		{"[a]([bc][cb]=(b)c=)", "[a][bc][cb]", "[a][cb][bc]"},
+		{"[a]([b]=[x][y])[c]", "[a][b][c]", "[a][x][y][c]"},
+		{"=[a]([b][c])", "[a][b][c]", "[b][c][a]"},
+		{"{a:x,b:y}+{c:z,a:}", "{a:x,b:y}", "{b:y,c:z}"},
+		{"{a:x}+{a:y}", "{a:x}", "{a:y}"},
+		{"{}+{a:y}", "{}", "{a:y}"},
+		{"{Key1:(Big=Bad) Wolf}", "{Key1:Big Wolf}", "{Key1:Bad Wolf}"},
+		{"{Key1:{Inner1:1}+{Inner2:2}}", "{Key1:{Inner1:1}}", "{Key1:{Inner1:1,Inner2:2}}"},
+		{"[h][e]({key:a}+{key:b})[l][o]", "[h][e][{key:a}][l][o]", "[h][e][{key:b}][l][o]"},
+		{"[h][e]((l=x)l)", "[h][e][ll]", "[h][e][xl]"},
+		{"[a](x=y(z))", "[a][xyz]", "[a][xzy]"},
+		{"[a]((z)x=y)", "[a][zxy]", "[a][xzy]"},
+		{"[a]([b]([c]=[d][e]))", "[a][[b][c]]", "[a][[b][d][e]]"},
+		{"{k:[a]([bc][bc]=(b=x)c)}", "{k:[a][bc][bc]}", "{k:[a][xc][xc]}"},
+		{`f\(x\) \= (1=2)`, `f\(x\) \= 1`, `f\(x\) \= 2`},
+		{`a\=(b)c=`, `a\=bc`, `a\=cb`},
+		{`{a\:b:(x\,=y\\)}`, `{a\:b:x\,}`, `{a\:b:y\\}`},
+		{`[\[]([x]=[\]])`, `[\[][x]`, `[\[][\]]`},
+		{`\[(\]=)`, `\[\]`, `\[`},
+	}
+
+	c := lib.Compact{}
+	for _, test := range tests {
+		input, ch := c.Decode(test[0])
+		if input != test[1] {
+			t.Error("Decode", test[0], "unexpected input", input)
+		}
+		if output := c.Apply(input, ch); output != test[2] {
+			t.Error("Apply", test[0], "unexpected output", output)
+		}
+		if encoded := c.Encode(input, ch); len(encoded) != 1 || encoded[0] != test[0] {
+			t.Error("Encode", test[0], "unexpected", encoded)
+		}
+	}
+}
+
+func TestCompactDecodeNestedPath(t *testing.T) {
+	c := lib.Compact{}
+	input, ch := c.Decode("he({key:a}+{key:b})lo")
+	if input != "[h][e][{key:a}][l][o]" {
+		t.Error("Unexpected input", input)
+	}
+
+	expected := changes.PathChange{
+		Path: []interface{}{2},
+		Change: changes.ChangeSet{changes.PathChange{
+			Path:   []interface{}{"key"},
+			Change: changes.Replace{Before: types.S16("a"), After: types.S16("b")},
+		}},
+	}
+	if !reflect.DeepEqual(ch, expected) {
+		t.Errorf("Unexpected change %#v", ch)
+	}
+
+	_, ch = c.Decode("{k:{x:[a]((b=c))}}")
+	expected2 := changes.PathChange{
+		Path:   []interface{}{"k", "x", 1},
+		Change: changes.Splice{Offset: 0, Before: types.S16("b"), After: types.S16("c")},
+	}
+	if !reflect.DeepEqual(ch, expected2) {
+		t.Errorf("Unexpected change %#v", ch)
+	}
+}
+
+func TestCompactDecodeRange(t *testing.T) {
+	c := lib.Compact{}
+	input, ch := c.Decode("he([ll][lo]=(l=l ))")
+	if input != "[h][e][ll][lo]" {
+		t.Error("Unexpected input", input)
+	}
+	if output := c.Apply(input, ch); output != "[h][e][l l][l o]" {
+		t.Error("Unexpected output", output)
+	}
+}
+
+func TestCompactEscapes(t *testing.T) {
+	c := lib.Compact{}
+	input, ch := c.Decode(`{\{\}:(=\=)\=}`)
+	if input != `{\{\}:\=}` {
+		t.Error("Unexpected input", input)
+	}
+
+	expected := changes.PathChange{
+		Path:   []interface{}{"{}"},
+		Change: changes.Splice{Offset: 0, Before: types.S16(""), After: types.S16("=")},
+	}
+	if !reflect.DeepEqual(ch, expected) {
+		t.Errorf("Unexpected change %#v", ch)
+	}
+
+	if v := c.DecodeValue(c.EncodeValue(types.S16(`a\(b)`))); v != types.S16(`a\(b)`) {
+		t.Errorf("Unexpected value %#v", v)
+	}
+}
+
+func TestCompactDecodeErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		offset  int
+		message string
+	}{
+		{"ab(c", 4, `expected ")"`},
+		{"ab(c]", 4, `expected ")"`},
+		{"ab)c", 2, `unexpected ")"`},
+		{"abc", 0, `expected a change`},
+		{"a=bc", 1, `unexpected "=" outside of a change`},
+		{"a(b=c)d(e=f)", 7, `unexpected "(" after a change`},
+		{"{a:b}x", 5, `expected "+"`},
+		{"{a:b}+x", 6, `expected "{"`},
+		{"{a:b}+{c}", 8, `expected ":"`},
+		{"[a]((b))", 5, `expected a change or "="`},
+		{"a(b=c:)", 5, `unexpected ":"`},
+		{"{a:x(b=c:)}", 8, `unexpected ":"`},
+		{`a(b=c)\`, 6, `expected a character after "\\"`},
+	}
+
+	c := lib.Compact{}
+	for _, test := range tests {
+		_, _, err := c.DecodeE(test.input)
+		perr, ok := err.(*lib.ParseError)
+		if !ok {
+			t.Error("Unexpected error", test.input, err)
+			continue
+		}
+		if perr.Offset != test.offset || perr.Message != test.message || perr.Input != test.input {
+			t.Errorf("%s: unexpected error %#v", test.input, perr)
+		}
+	}
+}
+
+func TestCompactEncodeErrors(t *testing.T) {
+	c := lib.Compact{}
+	replace := changes.Replace{Before: types.S16("a"), After: types.S16("b")}
+	if _, err := c.EncodeE("a", replace); err == nil {
+		t.Error("Unexpected success")
+	} else if _, ok := err.(*lib.EncodeError); !ok {
+		t.Error("Unexpected error", err)
+	}
+
+	if _, err := c.EncodeE("a(b", nil); err == nil {
+		t.Error("Unexpected success")
+	} else if _, ok := err.(*lib.ParseError); !ok {
+		t.Error("Unexpected error", err)
+	}
+
+	splice := changes.Splice{Offset: 0, Before: types.S16("a"), After: types.S16("x")}
+	tests := []struct {
+		input string
+		ch    changes.Change
+	}{
+		{"ab", changes.Splice{Offset: -1, Before: types.S16(""), After: types.S16("x")}},
+		{"ab", changes.Splice{Offset: 2, Before: types.S16("b"), After: types.S16("x")}},
+		{"ab", changes.Splice{Offset: 1, After: types.S16("x")}},
+		{"ab", changes.Move{Offset: 1, Count: 2, Distance: 0}},
+		{"ab", changes.Move{Offset: 0, Count: 1, Distance: 2}},
+		{"ab", changes.Move{Offset: 1, Count: 1, Distance: -2}},
+		{"ab", changes.Move{Offset: 0, Count: -1, Distance: 1}},
+		{"[a][b]", changes.PathChange{Path: []interface{}{"a"}, Change: splice}},
+		{"[a][b]", changes.PathChange{Path: []interface{}{2}, Change: splice}},
+		{"[a][b]", changes.PathChange{Path: []interface{}{-1}, Change: splice}},
+		{"[a][b]", changes.ChangeSet{
+			changes.PathChange{Path: []interface{}{1}, Change: splice},
+			changes.PathChange{Path: []interface{}{2}, Change: splice},
+		}},
+		{"{a:b}", changes.PathChange{Path: []interface{}{"c"}, Change: splice}},
+		{"{a:b}", changes.PathChange{Path: []interface{}{0}, Change: splice}},
+		{"{a:b}", changes.PathChange{Path: []interface{}{0}, Change: changes.Replace{Before: changes.Nil, After: types.S16("x")}}},
+		{"{a:b}", changes.PathChange{Path: []interface{}{"a"}, Change: changes.Replace{Before: types.S16("b"), After: types.M{0: types.S16("x")}}}},
+		{"{a:b}", changes.PathChange{Path: []interface{}{"a"}, Change: changes.Replace{Before: types.S16("b"), After: types.S16("")}}},
+		{"{a:b}", changes.ChangeSet{
+			changes.PathChange{Path: []interface{}{"a"}, Change: changes.Replace{Before: types.S16("b"), After: changes.Nil}},
+			changes.PathChange{Path: []interface{}{"c"}, Change: changes.Replace{Before: changes.Nil, After: types.S16("")}},
+		}},
+	}
+	for _, test := range tests {
+		if _, err := c.EncodeE(test.input, test.ch); err == nil {
+			t.Errorf("%s: unexpected success %#v", test.input, test.ch)
+		} else if _, ok := err.(*lib.EncodeError); !ok {
+			t.Errorf("%s: unexpected error %v", test.input, err)
+		}
+	}
+}
+
+func TestCompactDecodeMixedSplice(t *testing.T) {
+	tests := map[string]changes.Change{
+		"a(b=[x])c":  changes.Splice{Offset: 1, Before: types.A{types.S16("b")}, After: types.A{types.S16("x")}},
+		"[a]([b]=x)": changes.Splice{Offset: 1, Before: types.A{types.S16("b")}, After: types.A{types.S16("x")}},
+		"a([x]=)":    changes.Splice{Offset: 1, Before: types.A{types.S16("x")}, After: types.A{}},
+	}
+
+	c := lib.Compact{}
+	for input, expected := range tests {
+		_, ch, err := c.DecodeE(input)
+		if err != nil || !reflect.DeepEqual(ch, expected) {
+			t.Errorf("%s: unexpected %#v %v", input, ch, err)
+		}
+	}
+}
+
+func TestCompactDecodeSeq(t *testing.T) {
+	c := lib.Compact{}
+	seq := []string{"a(b=c)", "ac(=d)", "[a][b]([c]=(c=e))"}
+	if _, _, err := c.DecodeSeq(seq); err == nil {
+		t.Error("Unexpected success with mismatched steps")
+	}
+
+	seq = []string{"a(b=c)", "ac(=d)", "(a=x)cd"}
+	input, cs, err := c.DecodeSeq(seq)
+	if err != nil || input != "ab" || len(cs) != 3 {
+		t.Fatal("Unexpected", input, cs, err)
+	}
+	if output := c.Apply(input, cs); output != "xcd" {
+		t.Error("Unexpected output", output)
+	}
+	if encoded := c.Encode(input, cs); !reflect.DeepEqual(encoded, seq) {
+		t.Error("Unexpected encoding", encoded)
+	}
+
+	if _, _, err := c.DecodeSeq([]string{"a(b=c)", "a(b"}); err == nil {
+		t.Error("Unexpected success with malformed step")
+	}
+
+	if input, cs, err := c.DecodeSeq(nil); input != "" || cs != nil || err != nil {
+		t.Error("Unexpected", input, cs, err)
+	}
+}
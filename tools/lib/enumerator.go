@@ -0,0 +1,121 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+)
+
+// Enumerator is implemented by all the families of operations
+// (Splices, Moves, Ranges, Sets and Nested).  ForEach calls fn with
+// every operation of the family encoded as in this spec:
+// http://github.com/dotchain/dataset/CompactJSON.md
+type Enumerator interface {
+	ForEach(fn func(op string))
+}
+
+// Enumerators combines several enumerators into one that generates
+// the operations of each of them in order
+type Enumerators []Enumerator
+
+// ForEach implements Enumerator
+func (e Enumerators) ForEach(fn func(string)) {
+	for _, x := range e {
+		x.ForEach(fn)
+	}
+}
+
+// Nested lifts the operations of another enumerator to the value at
+// Key (an int index for arrays and a string for maps) of the
+// container whose compact form is Input.  This allows operations on
+// strings to be paired with operations on the container itself, such
+// as a splice within an element of an array with a range operation
+// on the array.
+//
+// Operations of Inner that do not apply to the value at Key are
+// skipped.
+type Nested struct {
+	Input string
+	Key   interface{}
+	Inner Enumerator
+}
+
+// ForEach implements Enumerator
+func (n *Nested) ForEach(fn func(string)) {
+	c := Compact{}
+	path := []interface{}{n.Key}
+	elem := n.elem(c.DecodeValue(n.Input))
+	if elem == nil {
+		return
+	}
+
+	value := c.EncodeValue(elem)
+	n.Inner.ForEach(func(op string) {
+		input, ch := c.Decode(op)
+		if input == value && ch != nil {
+			fn(c.Encode1(n.Input, changes.PathChange{Path: path, Change: ch}))
+		}
+	})
+}
+
+// elem returns the value at Key or nil if there is no such value
+func (n *Nested) elem(container changes.Value) changes.Value {
+	switch container := container.(type) {
+	case types.A:
+		if key, ok := n.Key.(int); ok && key >= 0 && key < len(container) {
+			return container[key]
+		}
+	case types.M:
+		return container[n.Key]
+	}
+	return nil
+}
+
+// ForEachUniqueCrossPair pairs every operation of left with every
+// operation of right on the same input and calls fn with the unique
+// pairs, using the provided alphabet for the "uniqueness"
+// calculation.  Pairs of operations on different inputs (such as a
+// splice on a string and a set operation on a map) are skipped.
+//
+// The characters of each pair are mapped to the alphabet in order of
+// appearance (see rename), so pairs that only differ by the choice of
+// characters are considered the same.  The left operation of each
+// pair is always from left, so callers wanting both orders should
+// also call this with the enumerators swapped.  An error is returned
+// if the alphabet has too few letters.
+func ForEachUniqueCrossPair(alphabet []string, left, right Enumerator, fn func(input, left, right string)) error {
+	c := Compact{}
+	type op struct{ input, op string }
+	rights := []op{}
+	right.ForEach(func(s string) {
+		input, _ := c.Decode(s)
+		rights = append(rights, op{input, s})
+	})
+
+	seen := map[string]bool{}
+	var err error
+	left.ForEach(func(l string) {
+		input, _ := c.Decode(l)
+		for _, r := range rights {
+			var normalized []string
+			if err != nil {
+				return
+			}
+			if r.input != input {
+				continue
+			}
+			if normalized, err = rename(alphabet, input, l, r.op); err != nil {
+				return
+			}
+			key := normalized[0] + "|||" + normalized[1] + "|||" + normalized[2]
+			if !seen[key] {
+				seen[key] = true
+				fn(normalized[0], normalized[1], normalized[2])
+			}
+		}
+	})
+	return err
+}
@@ -0,0 +1,136 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package lib_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dotchain/dataset/tools/lib"
+)
+
+var enumerators = []lib.Enumerator{
+	&lib.Splices{},
+	&lib.Moves{},
+	&lib.Ranges{},
+	&lib.Sets{},
+	&lib.Nested{},
+}
+
+func ExampleForEachUniqueCrossPair() {
+	ranges := &lib.Ranges{Input: []string{"ab", "ab"}, Inserts: []string{"", "x"}}
+	splices := &lib.Nested{
+		Input: "[ab][ab]",
+		Key:   1,
+		Inner: &lib.Splices{Input: "ab", Inserts: []string{"", "x"}},
+	}
+	alphabet := strings.Split("abcdefghijklmnopqrstuvwxyz", "")
+	seen := map[string]bool{}
+	lib.ForEachUniqueCrossPair(alphabet, splices, ranges, func(i, l, r string) {
+		if seen[i+"-->"+l+r] {
+			fmt.Println("Unexpected duplicate")
+		}
+		seen[i+"-->"+l+r] = true
+	})
+	fmt.Println("Number of unique pairs =", len(seen))
+
+	// Output: Number of unique pairs = 396
+}
+
+func TestNested(t *testing.T) {
+	splices := &lib.Splices{Input: "ab", Inserts: []string{"", "x"}}
+	inner := map[string]bool{}
+	splices.ForEach(func(op string) { inner[op] = true })
+
+	n := &lib.Nested{Input: "{a:ab,b:c}", Key: "a", Inner: splices}
+	seen := map[string]bool{}
+	n.ForEach(func(op string) {
+		if seen[op] {
+			t.Error("Duplicate", op)
+		}
+		seen[op] = true
+	})
+	if len(seen) != len(inner) || !seen["{a:a(b=x),b:c}"] {
+		t.Error("Unexpected ops", len(seen), len(inner))
+	}
+
+	mismatched := []*lib.Nested{
+		{Input: "{a:ab,b:c}", Key: "b", Inner: splices},
+		{Input: "{a:ab,b:c}", Key: "c", Inner: splices},
+		{Input: "[ab][c]", Key: 2, Inner: splices},
+		{Input: "[ab][c]", Key: "a", Inner: splices},
+	}
+	for _, n := range mismatched {
+		n.ForEach(func(op string) {
+			t.Error("Unexpected op", n.Key, op)
+		})
+	}
+}
+
+func TestForEachUniqueCrossPair(t *testing.T) {
+	splices := &lib.Splices{Input: "abc", Inserts: []string{"", "x"}}
+	moves := &lib.Moves{Input: "abc"}
+	alphabet := strings.Split("abcdefghijklmnopqrstuvwxyz", "")
+	c := lib.Compact{}
+
+	count := 0
+	err := lib.ForEachUniqueCrossPair(alphabet, splices, moves, func(i, l, r string) {
+		count++
+		if x, _ := c.Decode(l); x != i {
+			t.Error("Unexpected left", i, l)
+		}
+		if x, _ := c.Decode(r); x != i {
+			t.Error("Unexpected right", i, r)
+		}
+	})
+	if err != nil || count == 0 {
+		t.Error("No pairs", err)
+	}
+
+	other := &lib.Moves{Input: "abcd"}
+	err = lib.ForEachUniqueCrossPair(alphabet, splices, other, func(i, l, r string) {
+		t.Error("Unexpected pair", i, l, r)
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = lib.ForEachUniqueCrossPair(alphabet[:2], splices, moves, func(i, l, r string) {
+		t.Error("Unexpected pair", i, l, r)
+	})
+	if err == nil {
+		t.Error("Expected an error")
+	}
+}
+
+func TestForEachUniqueCrossPairMaps(t *testing.T) {
+	// "b" is renamed to "a" and "a" to "b", which swaps the keys
+	sets := &lib.Sets{
+		Input:  map[string]string{"ba": "x", "bb": "y"},
+		Keys:   []string{"bb"},
+		Values: []string{"z"},
+	}
+	nested := &lib.Nested{
+		Input: "{ba:x,bb:y}",
+		Key:   "ba",
+		Inner: &lib.Splices{Input: "x", Inserts: []string{"", "z"}},
+	}
+	alphabet := strings.Split("abcdefghijklmnopqrstuvwxyz", "")
+	c := lib.Compact{}
+
+	count := 0
+	err := lib.ForEachUniqueCrossPair(alphabet, nested, sets, func(i, l, r string) {
+		count++
+		for _, op := range []string{l, r} {
+			if input, ch := c.Decode(op); input != i || c.Encode1(input, ch) != op {
+				t.Error("Unexpected op", i, op)
+			}
+		}
+	})
+	if err != nil || count == 0 {
+		t.Error("No pairs", err)
+	}
+}
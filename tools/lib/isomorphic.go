@@ -5,6 +5,7 @@
 package lib
 
 import (
+	"fmt"
 	"sort"
 	"strings"
 )
@@ -125,3 +126,57 @@ func getAllCommonSubsequences(s []string, punctuations string) []string {
 	}
 	return result
 }
+
+// rename maps every character of the provided strings to a letter of
+// the alphabet in order of appearance and returns the strings in their
+// canonical compact form.  Unlike Normalize, this works with repeated
+// characters but does not merge characters that always appear
+// together.  The special characters of the compact form are left
+// alone.
+//
+// Renaming can change the order of the keys of maps, so the renamed
+// strings are decoded and encoded again to keep the keys sorted.  An
+// error is returned if the alphabet has too few letters.
+func rename(alphabet []string, strs ...string) ([]string, error) {
+	pairs := []string{}
+	seen := map[rune]bool{}
+	for _, s := range strs {
+		for _, ch := range s {
+			if seen[ch] || strings.ContainsRune(specials, ch) {
+				continue
+			}
+			if len(pairs)/2 == len(alphabet) {
+				return nil, fmt.Errorf("lib: alphabet of %d letters is too short for %q", len(alphabet), strs)
+			}
+			seen[ch] = true
+			pairs = append(pairs, string(ch), alphabet[len(pairs)/2])
+		}
+	}
+
+	replacer := strings.NewReplacer(pairs...)
+	result := make([]string, len(strs))
+	for kk, s := range strs {
+		var err error
+		if result[kk], err = canonical(replacer.Replace(s)); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// canonical decodes a compact form string (a plain value or a change)
+// and encodes it again
+func canonical(s string) (result string, err error) {
+	c := Compact{}
+	v, ch, err := c.DecodeE(s)
+	if err != nil {
+		// plain values such as inputs have no change
+		if v, err = c.DecodeValueE(s); err != nil {
+			return "", err
+		}
+		return c.EncodeValue(v), nil
+	}
+
+	defer c.recover(s, &err)
+	return c.Encode1(c.EncodeValue(v), ch), nil
+}
@@ -0,0 +1,152 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/dotchain/dot/changes"
+)
+
+// Journal is the sequence of operations as received by a server from
+// all its clients.  Please see json/journal_suite.json
+type Journal []JournalEntry
+
+// JournalEntry is a single operation in a journal.
+//
+// Parent is the ID of the last entry of the journal that the client
+// had seen when it made the operation and Basis is the ID of the
+// previous operation by the same client.  Either can be empty.  Op
+// is in the compact form and applies to the state of the client
+// when it made the operation.
+type JournalEntry struct {
+	ID, Parent, Basis, Op string
+}
+
+// MarshalJSON encodes the entry as an array of 4 strings
+func (e JournalEntry) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]string{e.ID, e.Parent, e.Basis, e.Op})
+}
+
+// UnmarshalJSON decodes an array of 4 strings
+func (e *JournalEntry) UnmarshalJSON(data []byte) error {
+	var row []string
+	if err := json.Unmarshal(data, &row); err != nil {
+		return err
+	}
+	if len(row) != 4 {
+		return fmt.Errorf("journal: expected 4 fields, got %d", len(row))
+	}
+	e.ID, e.Parent, e.Basis, e.Op = row[0], row[1], row[2], row[3]
+	return nil
+}
+
+// Rebase transforms every operation in the journal to apply on top
+// of all the operations before it.  It also returns the merge chain
+// of every operation: the operations the client had not seen,
+// transformed to apply on top of the operation.
+//
+// The operations a client had not seen are those in the merge chain
+// of its previous operation that were not already seen by it (as
+// indicated by the parent) followed by all the operations after both
+// its parent and its previous operation.
+//
+// Each unseen operation is the left side of the merge with the
+// client operation on the right, so server operations win ties such
+// as insertions at the same offset.
+//
+// All rebased operations and merge chain entries must be single
+// operations in the compact form.  An empty string is used for
+// operations that have been fully absorbed by the merge.
+func (j Journal) Rebase() (rebased []string, mergeChains [][]string, err error) {
+	return j.RebaseWith(changes.Merge)
+}
+
+// RebaseWith is like Rebase but uses the provided merge function
+// instead of changes.Merge
+func (j Journal) RebaseWith(merge func(l, r changes.Change) (changes.Change, changes.Change)) (rebased []string, mergeChains [][]string, err error) {
+	type step struct {
+		at int
+		ch changes.Change
+	}
+
+	c := Compact{}
+	encode := func(v changes.Value, ch changes.Change) (string, error) {
+		seq, err := c.EncodeE(c.EncodeValue(v), ch)
+		switch {
+		case err != nil:
+			return "", err
+		case len(seq) > 1:
+			return "", fmt.Errorf("journal: %q is not a single operation", seq)
+		case len(seq) == 0:
+			return "", nil
+		}
+		return seq[0], nil
+	}
+
+	index := map[string]int{"": -1}
+	chains := make([][]step, len(j))
+	ops := make([]changes.Change, len(j))
+	var server changes.Value
+	for kk, e := range j {
+		if _, ok := index[e.ID]; ok {
+			return nil, nil, fmt.Errorf("journal: duplicate or empty id %q", e.ID)
+		}
+		parent, ok1 := index[e.Parent]
+		basis, ok2 := index[e.Basis]
+		if !ok1 || !ok2 {
+			return nil, nil, fmt.Errorf("journal: %s refers to an unknown entry", e.ID)
+		}
+
+		v, x, err := c.DecodeE(e.Op)
+		if err != nil {
+			return nil, nil, err
+		}
+		if kk == 0 {
+			server = v
+		}
+
+		unseen := []step(nil)
+		if basis >= 0 {
+			for _, s := range chains[basis] {
+				if s.at > parent {
+					unseen = append(unseen, s)
+				}
+			}
+		}
+		if basis > parent {
+			parent = basis
+		}
+		for at := parent + 1; at < kk; at++ {
+			unseen = append(unseen, step{at, ops[at]})
+		}
+
+		chain := []string{}
+		for _, u := range unseen {
+			xx, ux := merge(u.ch, x)
+			s, err := encode(v.Apply(nil, x), ux)
+			if err != nil {
+				return nil, nil, err
+			}
+			chain = append(chain, s)
+			chains[kk] = append(chains[kk], step{u.at, ux})
+			v, x = v.Apply(nil, u.ch), xx
+		}
+
+		if c.EncodeValue(v) != c.EncodeValue(server) {
+			return nil, nil, fmt.Errorf("journal: %s does not apply to %q", e.ID, c.EncodeValue(server))
+		}
+
+		s, err := encode(server, x)
+		if err != nil {
+			return nil, nil, err
+		}
+		rebased = append(rebased, s)
+		mergeChains = append(mergeChains, chain)
+		server, ops[kk], index[e.ID] = server.Apply(nil, x), x, kk
+	}
+	return rebased, mergeChains, nil
+}
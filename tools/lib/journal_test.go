@@ -0,0 +1,88 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package lib_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/dotchain/dataset/tools/lib"
+)
+
+func TestJournalRebase(t *testing.T) {
+	tests := []struct {
+		journal     string
+		rebased     []string
+		mergeChains [][]string
+	}{
+		{
+			`[["s0", "", "", "abc(=X)d"],
+			  ["s1", "s0", "", "a(b=Y)cXd"],
+			  ["c0", "", "", "a(=x)bcd"],
+			  ["c1", "", "c0", "axb(c=y)d"]]`,
+			[]string{"abc(=X)d", "a(b=Y)cXd", "a(=x)YcXd", "axY(c=y)Xd"},
+			[][]string{
+				{},
+				{},
+				{"axbc(=X)d", "ax(b=Y)cXd"},
+				{"axby(=X)d", "ax(b=Y)yXd"},
+			},
+		},
+		{
+			`[["s0", "", "", "(=hello world)"],
+			  ["s1", "s0", "", "hello (=beautiful )world"],
+			  ["c0", "s0", "", "hello (=crazy )world"],
+			  ["x0", "s0", "", "hello world(=!)"],
+			  ["c1", "s1", "c0", "hello beautiful cra(z=)y world"]]`,
+			[]string{
+				"(=hello world)",
+				"hello (=beautiful )world",
+				"hello beautiful (=crazy )world",
+				"hello beautiful crazy world(=!)",
+				"hello beautiful cra(z=)y world!",
+			},
+			[][]string{
+				{},
+				{},
+				{"hello (=beautiful )crazy world"},
+				{"hello (=beautiful )world!", "hello beautiful (=crazy )world!"},
+				{"hello beautiful cray world(=!)"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		var j lib.Journal
+		if err := json.Unmarshal([]byte(test.journal), &j); err != nil {
+			t.Fatal(err)
+		}
+		rebased, mergeChains, err := j.Rebase()
+		if err != nil {
+			t.Error("Rebase", test.journal, err)
+			continue
+		}
+		if !reflect.DeepEqual(rebased, test.rebased) {
+			t.Error("Rebase", test.journal, "unexpected rebased", rebased)
+		}
+		if !reflect.DeepEqual(mergeChains, test.mergeChains) {
+			t.Error("Rebase", test.journal, "unexpected merge chains", mergeChains)
+		}
+	}
+}
+
+func TestJournalErrors(t *testing.T) {
+	journals := []lib.Journal{
+		{{"s0", "", "", "a(=b)"}, {"s0", "", "", "a(=c)"}},
+		{{"s0", "", "", "a(=b)"}, {"s1", "c0", "", "a(=c)"}},
+		{{"s0", "", "", "a(=b)"}, {"s1", "s0", "", "xyz(=c)"}},
+		{{"s0", "", "", "a(=b"}},
+	}
+	for _, j := range journals {
+		if _, _, err := j.Rebase(); err == nil {
+			t.Error("Rebase unexpectedly succeeded", j)
+		}
+	}
+}
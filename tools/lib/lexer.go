@@ -0,0 +1,61 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokText tokenKind = iota
+	tokPunct
+	tokEOF
+)
+
+// token is either a single (unescaped) character of text or one of
+// the special characters
+type token struct {
+	kind tokenKind
+	at   int
+	text string
+}
+
+func (t token) is(punct string) bool {
+	return t.kind == tokPunct && t.text == punct
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of input"
+	}
+	return t.text
+}
+
+// lex splits s into tokens, the last of which is always tokEOF
+func lex(s string) []token {
+	result := []token{}
+	for offset := 0; offset < len(s); {
+		switch ch := s[offset]; {
+		case ch == '\\':
+			if offset+1 == len(s) {
+				fail(offset, "expected a character after %q", "\\")
+			}
+			_, size := utf8.DecodeRuneInString(s[offset+1:])
+			result = append(result, token{tokText, offset, s[offset+1 : offset+1+size]})
+			offset += 1 + size
+		case strings.IndexByte(specials, ch) >= 0:
+			result = append(result, token{tokPunct, offset, s[offset : offset+1]})
+			offset++
+		default:
+			_, size := utf8.DecodeRuneInString(s[offset:])
+			result = append(result, token{tokText, offset, s[offset : offset+size]})
+			offset += size
+		}
+	}
+	return append(result, token{tokEOF, len(s), ""})
+}
@@ -0,0 +1,246 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+)
+
+// Native converts dot changes to and from JSON objects that mirror
+// the dot types, for ports that do not have a compact form parser.
+//
+// Values are plain JSON: strings for types.S16, arrays for types.A,
+// objects for types.M and null for changes.Nil.  Changes are objects
+// with a single key naming the type:
+//
+//	{"Splice": {"Offset": 1, "Before": "b", "After": "xy"}}
+//	{"Move": {"Offset": 1, "Count": 2, "Distance": 1}}
+//	{"Replace": {"Before": "a", "After": null}}
+//	{"PathChange": {"Path": [1, "key"], "Change": {...}}}
+//	{"ChangeSet": [{...}, ...]}
+//
+// A nil change is null.  The JSON values are those used by
+// encoding/json when decoding into an interface{}.
+type Native struct{}
+
+// EncodeChange converts a change to its JSON object
+func (n Native) EncodeChange(c changes.Change) (interface{}, error) {
+	switch c := c.(type) {
+	case nil:
+		return nil, nil
+	case changes.Splice:
+		before, err1 := n.EncodeValue(c.Before)
+		after, err2 := n.EncodeValue(c.After)
+		return n.object("Splice", map[string]interface{}{
+			"Offset": c.Offset,
+			"Before": before,
+			"After":  after,
+		}, err1, err2)
+	case changes.Move:
+		return n.object("Move", map[string]interface{}{
+			"Offset":   c.Offset,
+			"Count":    c.Count,
+			"Distance": c.Distance,
+		})
+	case changes.Replace:
+		before, err1 := n.EncodeValue(c.Before)
+		after, err2 := n.EncodeValue(c.After)
+		return n.object("Replace", map[string]interface{}{
+			"Before": before,
+			"After":  after,
+		}, err1, err2)
+	case changes.PathChange:
+		inner, err := n.EncodeChange(c.Change)
+		return n.object("PathChange", map[string]interface{}{
+			"Path":   c.Path,
+			"Change": inner,
+		}, err)
+	case changes.ChangeSet:
+		result := make([]interface{}, len(c))
+		errs := make([]error, len(c))
+		for kk, cx := range c {
+			result[kk], errs[kk] = n.EncodeChange(cx)
+		}
+		return n.object("ChangeSet", result, errs...)
+	}
+	return nil, &EncodeError{c}
+}
+
+// EncodeValue converts a value to its JSON equivalent
+func (n Native) EncodeValue(v changes.Value) (interface{}, error) {
+	switch v := v.(type) {
+	case types.S16:
+		return string(v), nil
+	case types.A:
+		result := make([]interface{}, len(v))
+		for kk, elt := range v {
+			var err error
+			if result[kk], err = n.EncodeValue(elt); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	case types.M:
+		result := map[string]interface{}{}
+		for key, elt := range v {
+			k, ok := key.(string)
+			if !ok {
+				return nil, &EncodeError{v}
+			}
+			var err error
+			if result[k], err = n.EncodeValue(elt); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+	if v == changes.Nil {
+		return nil, nil
+	}
+	return nil, &EncodeError{v}
+}
+
+// DecodeChange converts the JSON object of a change back to the
+// change.  This is the inverse of EncodeChange.
+func (n Native) DecodeChange(v interface{}) (changes.Change, error) {
+	if v == nil {
+		return nil, nil
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok || len(obj) != 1 {
+		return nil, fmt.Errorf("native: invalid change %v", v)
+	}
+
+	var kind string
+	var body interface{}
+	for kind, body = range obj {
+	}
+
+	if kind == "ChangeSet" {
+		elts, ok := body.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("native: invalid ChangeSet %v", body)
+		}
+		result := make(changes.ChangeSet, len(elts))
+		for kk, elt := range elts {
+			var err error
+			if result[kk], err = n.DecodeChange(elt); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+
+	fields, ok := body.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("native: invalid %s %v", kind, body)
+	}
+	return n.decodeFields(kind, fields)
+}
+
+func (n Native) decodeFields(kind string, fields map[string]interface{}) (changes.Change, error) {
+	var err error
+	count := func(key string) int {
+		f, ok := fields[key].(float64)
+		if !ok || f != math.Trunc(f) {
+			err = fmt.Errorf("native: %s.%s is not an integer", kind, key)
+		}
+		return int(f)
+	}
+	value := func(key string) changes.Value {
+		if _, ok := fields[key]; !ok {
+			err = fmt.Errorf("native: %s.%s is missing", kind, key)
+			return nil
+		}
+		v, err1 := n.DecodeValue(fields[key])
+		if err1 != nil {
+			err = err1
+		}
+		return v
+	}
+	collection := func(key string) changes.Collection {
+		c, ok := value(key).(changes.Collection)
+		if !ok && err == nil {
+			err = fmt.Errorf("native: %s.%s is not a string or an array", kind, key)
+		}
+		return c
+	}
+
+	var result changes.Change
+	switch kind {
+	case "Splice":
+		result = changes.Splice{Offset: count("Offset"), Before: collection("Before"), After: collection("After")}
+	case "Move":
+		result = changes.Move{Offset: count("Offset"), Count: count("Count"), Distance: count("Distance")}
+	case "Replace":
+		result = changes.Replace{Before: value("Before"), After: value("After")}
+	case "PathChange":
+		path, ok := fields["Path"].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("native: invalid PathChange.Path %v", fields["Path"])
+		}
+		path = append([]interface{}(nil), path...)
+		for kk, key := range path {
+			if f, ok := key.(float64); ok && f == math.Trunc(f) {
+				path[kk] = int(f)
+			} else if _, ok := key.(string); !ok {
+				return nil, fmt.Errorf("native: invalid PathChange.Path %v", fields["Path"])
+			}
+		}
+		inner, err := n.DecodeChange(fields["Change"])
+		if err != nil {
+			return nil, err
+		}
+		result = changes.PathChange{Path: path, Change: inner}
+	default:
+		return nil, fmt.Errorf("native: unknown change %s", kind)
+	}
+	return result, err
+}
+
+// DecodeValue converts a JSON value back to the dot value.  This is
+// the inverse of EncodeValue.
+func (n Native) DecodeValue(v interface{}) (changes.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return changes.Nil, nil
+	case string:
+		return types.S16(v), nil
+	case []interface{}:
+		result := make(types.A, len(v))
+		for kk, elt := range v {
+			var err error
+			if result[kk], err = n.DecodeValue(elt); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	case map[string]interface{}:
+		result := types.M{}
+		for key, elt := range v {
+			var err error
+			if result[key], err = n.DecodeValue(elt); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("native: invalid value %v", v)
+}
+
+// object wraps the body of a change with its type, returning the
+// first of the errors if any
+func (n Native) object(kind string, body interface{}, errs ...error) (interface{}, error) {
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return map[string]interface{}{kind: body}, nil
+}
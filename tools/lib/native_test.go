@@ -0,0 +1,108 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package lib_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/dotchain/dataset/tools/lib"
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+)
+
+func TestNative(t *testing.T) {
+	n := lib.Native{}
+	tests := []struct {
+		change changes.Change
+		json   string
+	}{
+		{nil, `null`},
+		{changes.Splice{Offset: 1, Before: types.S16("b"), After: types.S16("xy")}, `{"Splice":{"After":"xy","Before":"b","Offset":1}}`},
+		{changes.Move{Offset: 1, Count: 2, Distance: -1}, `{"Move":{"Count":2,"Distance":-1,"Offset":1}}`},
+		{changes.Replace{Before: types.M{"a": types.A{}}, After: changes.Nil}, `{"Replace":{"After":null,"Before":{"a":[]}}}`},
+		{changes.PathChange{Path: []interface{}{1, "x"}, Change: changes.ChangeSet{nil}}, `{"PathChange":{"Change":{"ChangeSet":[null]},"Path":[1,"x"]}}`},
+	}
+
+	for _, test := range tests {
+		v, err := n.EncodeChange(test.change)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := json.Marshal(v)
+		if err != nil || string(data) != test.json {
+			t.Error("Unexpected encoding", string(data), err)
+		}
+
+		var decoded interface{}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		c, err := n.DecodeChange(decoded)
+		if err != nil || !reflect.DeepEqual(c, test.change) {
+			t.Errorf("Unexpected decoding %#v %v", c, err)
+		}
+	}
+}
+
+func TestNativeCompact(t *testing.T) {
+	c, n := lib.Compact{}, lib.Native{}
+	for _, s := range []string{"a(b=xy)c", "(Bad )Big =Wolf", "[h]([e]=[x][y])[ll]", "{Key1:(Big=Bad) Wolf}", "he({key:a}+{key:b})lo"} {
+		input, change := c.Decode(s)
+		expected := c.Encode1(input, change)
+
+		v, err := n.EncodeChange(change)
+		if err != nil {
+			t.Fatal(s, err)
+		}
+		data, _ := json.Marshal(v)
+		var decoded interface{}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if change, err = n.DecodeChange(decoded); err != nil {
+			t.Fatal(s, err)
+		}
+		if x := c.Encode1(input, change); x != expected {
+			t.Error("Unexpected round trip", expected, x)
+		}
+	}
+}
+
+func TestNativeErrors(t *testing.T) {
+	n := lib.Native{}
+	if _, err := n.EncodeChange(changes.Replace{Before: changes.Atomic{Value: 1}, After: changes.Nil}); err == nil {
+		t.Error("Unexpected success")
+	}
+	if _, err := n.EncodeValue(types.M{1: types.S16("x")}); err == nil {
+		t.Error("Unexpected success")
+	}
+
+	for _, str := range []string{
+		`5`,
+		`{}`,
+		`{"Splice": 5}`,
+		`{"Splice": {"Offset": 1.5, "Before": "", "After": "x"}}`,
+		`{"Splice": {"Offset": 1, "Before": ""}}`,
+		`{"Splice": {"Offset": 1, "Before": {}, "After": {}}}`,
+		`{"Move": {"Offset": 1, "Count": "x", "Distance": 1}}`,
+		`{"Replace": {"Before": 5, "After": null}}`,
+		`{"PathChange": {"Path": 1, "Change": null}}`,
+		`{"PathChange": {"Path": [true], "Change": null}}`,
+		`{"PathChange": {"Path": [1], "Change": 1}}`,
+		`{"ChangeSet": {}}`,
+		`{"ChangeSet": [1]}`,
+		`{"Other": {}}`,
+	} {
+		var v interface{}
+		if err := json.Unmarshal([]byte(str), &v); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := n.DecodeChange(v); err == nil {
+			t.Error("Unexpected success", str)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package lib
+
+import "fmt"
+
+// ParseError is the error returned when a compact form string is
+// malformed.  Offset is the byte offset within Input where the
+// problem was detected.
+type ParseError struct {
+	Input   string
+	Offset  int
+	Message string
+}
+
+// Error implements the error interface
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("compact: %s at offset %d of %q", e.Message, e.Offset, e.Input)
+}
+
+func fail(at int, format string, args ...interface{}) {
+	panic(&ParseError{Offset: at, Message: fmt.Sprintf(format, args...)})
+}
+
+// Parse parses a compact form string (as defined in CompactJSON.md)
+// into a syntax tree.  It returns a *ParseError if the string is
+// malformed.
+func Parse(s string) (n Node, err error) {
+	defer Compact{}.recover(s, &err)
+
+	p := &parser{tokens: lex(s)}
+	n = p.expr()
+	if t := p.peek(); t.kind != tokEOF {
+		fail(t.at, "unexpected %q", t)
+	}
+	return n, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) is(puncts ...string) bool {
+	for _, punct := range puncts {
+		if p.peek().is(punct) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) expect(punct string) token {
+	if !p.is(punct) {
+		fail(p.peek().at, "expected %q", punct)
+	}
+	return p.next()
+}
+
+// expr parses a value which may have a change embedded in it.  It
+// stops at the first token that cannot be part of it.
+func (p *parser) expr() Node {
+	if p.is("{") {
+		return p.mapExpr()
+	}
+	return p.seqExpr()
+}
+
+// seq is a sequence of characters and array elements.  Whether it is
+// a string or an array depends on the rest of the expression.
+type seq struct {
+	at    int
+	text  string
+	elems []Node
+	array bool
+}
+
+func (p *parser) items() *seq {
+	s := &seq{at: p.peek().at}
+	for {
+		switch t := p.peek(); {
+		case t.kind == tokText:
+			p.next()
+			s.text += t.text
+			s.elems = append(s.elems, &String{At: t.at, Text: t.text})
+		case t.is("["):
+			p.next()
+			elem := p.expr()
+			if IsChange(elem) {
+				fail(elem.Pos(), "unexpected change within %q", "[]")
+			}
+			p.expect("]")
+			s.elems = append(s.elems, elem)
+			s.array = true
+		default:
+			return s
+		}
+	}
+}
+
+func (p *parser) seqExpr() Node {
+	left := p.items()
+	switch {
+	case p.is("="):
+		eq := p.next()
+		skip := p.items()
+		if !p.is("(") {
+			fail(eq.at, "unexpected %q outside of a change", "=")
+		}
+		p.next()
+		mid := p.items()
+		p.expect(")")
+		right := p.items()
+		p.checkEnd()
+
+		nodes := p.seqNodes(left, skip, mid, right)
+		return &Move{left.at, nodes[0], nodes[1], nodes[2], nodes[3], true}
+	case p.is("("):
+		p.next()
+		n := p.paren(left)
+		p.checkEnd()
+		return n
+	}
+	return p.seqNodes(left)[0]
+}
+
+// paren parses the rest of the expression after the open parenthesis
+func (p *parser) paren(left *seq) Node {
+	start := p.pos
+	first := p.items()
+
+	switch {
+	case p.is("="):
+		p.next()
+		second := p.pos
+		after := p.items()
+		if p.is(")") {
+			p.next()
+			right := p.items()
+			nodes := p.seqNodes(left, first, after, right)
+			return &Splice{left.at, nodes[0], nodes[1], nodes[2], nodes[3]}
+		}
+		if !p.is("(", "=", "+", "{") {
+			fail(p.peek().at, "unexpected %q", p.peek())
+		}
+
+		p.pos = second
+		if len(first.elems) > 0 && p.isOp() {
+			op := p.expr()
+			p.expect(")")
+			right := p.items()
+			l, elems, r := p.array(left), p.array(first), p.array(right)
+			return &Range{left.at, l, elems, r, op}
+		}
+	case p.is(")"):
+		p.next()
+		skip := p.items()
+		if !p.is("=") {
+			fail(first.at, "expected a change or %q", "=")
+		}
+		p.next()
+		right := p.items()
+		nodes := p.seqNodes(left, first, skip, right)
+		return &Move{left.at, nodes[0], nodes[2], nodes[1], nodes[3], false}
+	}
+
+	p.pos = start
+	elem := p.expr()
+	p.expect(")")
+	if !IsChange(elem) {
+		fail(elem.Pos(), "expected a change or %q", "=")
+	}
+	right := p.items()
+	return &Path{left.at, p.array(left), p.array(right), elem}
+}
+
+// isOp checks if the tokens up to the closing parenthesis form a
+// change rather than a plain value
+func (p *parser) isOp() bool {
+	hasOp, hasEqual := false, false
+	depth := 0
+	for _, t := range p.tokens[p.pos:] {
+		switch {
+		case t.kind == tokEOF || depth == 0 && t.is(")"):
+			return hasOp && hasEqual
+		case t.is("="), t.is("+"):
+			hasEqual = true
+			hasOp = hasOp || depth == 0 && t.is("+")
+		case t.is("("), t.is("["), t.is("{"):
+			hasOp = hasOp || depth == 0 && t.is("(")
+			depth++
+		case t.is(")"), t.is("]"), t.is("}"):
+			depth--
+		}
+	}
+	return false
+}
+
+// checkEnd verifies that a change is not followed by another one
+func (p *parser) checkEnd() {
+	if t := p.peek(); t.is("(") || t.is("=") {
+		fail(t.at, "unexpected %q after a change", t)
+	}
+}
+
+// seqNodes converts the sequences into *Array if any of them is an
+// array and into *String otherwise
+func (p *parser) seqNodes(seqs ...*seq) []Node {
+	array := false
+	for _, s := range seqs {
+		array = array || s.array
+	}
+
+	result := make([]Node, len(seqs))
+	for kk, s := range seqs {
+		if array {
+			result[kk] = p.array(s)
+		} else {
+			result[kk] = &String{s.at, s.text}
+		}
+	}
+	return result
+}
+
+func (p *parser) array(s *seq) *Array {
+	return &Array{s.at, s.elems}
+}
+
+func (p *parser) mapExpr() Node {
+	m := p.mapNode()
+	if p.is("+") {
+		p.next()
+		if !p.is("{") {
+			fail(p.peek().at, "expected %q", "{")
+		}
+		set := p.mapNode()
+		for _, n := range []*Map{m, set} {
+			if IsChange(n) {
+				fail(n.At, "unexpected change within a set")
+			}
+		}
+		return &Set{m.At, m, set}
+	}
+
+	if t := p.peek(); t.kind != tokEOF && !p.is(",", "}", ")", "]") {
+		fail(t.at, "expected %q", "+")
+	}
+
+	seen := false
+	for _, e := range m.Entries {
+		if IsChange(e.Value) && seen {
+			fail(e.Value.Pos(), "unexpected second change")
+		}
+		seen = seen || IsChange(e.Value)
+	}
+	return m
+}
+
+func (p *parser) mapNode() *Map {
+	m := &Map{At: p.expect("{").at}
+	for !p.is("}") {
+		if len(m.Entries) > 0 {
+			p.expect(",")
+		}
+
+		e := &Entry{At: p.peek().at}
+		for p.peek().kind == tokText {
+			e.Key += p.next().text
+		}
+		p.expect(":")
+		e.Value = p.expr()
+		m.Entries = append(m.Entries, e)
+	}
+	p.next()
+	return m
+}
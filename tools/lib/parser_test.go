@@ -0,0 +1,80 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package lib_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dotchain/dataset/tools/lib"
+)
+
+func TestParsePrint(t *testing.T) {
+	tests := [][]string{
+		// input, printed
+		{"hello (rob=roy)!", "hello (rob=roy)!"},
+		{"=Bad (Big )Wolf", "=Bad (Big )Wolf"},
+		{"(Bad )Big =Wolf", "(Bad )Big =Wolf"},
+		{"oh[el][lo]", "[o][h][el][lo]"},
+		{"he([ll][lo]=(l=l ))", "[h][e]([ll][lo]=(l=l ))"},
+		{"he({key:a}+{key:b})lo", "[h][e]({key:a}+{key:b})[l][o]"},
+		{"{Key1:(Big=Bad) Wolf}", "{Key1:(Big=Bad) Wolf}"},
+		{"{key1:value1,key2:value2}+{key3:addition,key1:}", "{key1:value1,key2:value2}+{key3:addition,key1:}"},
+		{"[a](x=y(z))", "[a](x=y(z))"},
+		{`f\(x\) \= (1=2)`, `f\(x\) \= (1=2)`},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		n, err := lib.Parse(test[0])
+		if err != nil {
+			t.Error("Parse", test[0], err)
+			continue
+		}
+		if printed := lib.Print(n); printed != test[1] {
+			t.Error("Print", test[0], "unexpected", printed)
+		}
+	}
+}
+
+func TestParseNodes(t *testing.T) {
+	n, err := lib.Parse("h(e=x)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &lib.Splice{
+		At:     0,
+		Left:   &lib.String{At: 0, Text: "h"},
+		Before: &lib.String{At: 2, Text: "e"},
+		After:  &lib.String{At: 4, Text: "x"},
+		Right:  &lib.String{At: 6, Text: ""},
+	}
+	if !reflect.DeepEqual(n, expected) {
+		t.Errorf("Unexpected node %#v", n)
+	}
+
+	n, err = lib.Parse("[a]([b]=(b=c))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, ok := n.(*lib.Range)
+	if !ok || len(r.Left.Elems) != 1 || len(r.Elems.Elems) != 1 || len(r.Right.Elems) != 0 {
+		t.Fatalf("Unexpected node %#v", n)
+	}
+	if _, ok := r.Op.(*lib.Splice); !ok {
+		t.Errorf("Unexpected op %#v", r.Op)
+	}
+
+	for _, s := range []string{"abc", "[a][b]", "{a:b}"} {
+		if n, err := lib.Parse(s); err != nil || lib.IsChange(n) {
+			t.Error("Unexpected change", s, err)
+		}
+	}
+	for _, s := range []string{"a(b=)", "{a:(b=)}", "{a:b}+{a:}", "[a]((=b))"} {
+		if n, err := lib.Parse(s); err != nil || !lib.IsChange(n) {
+			t.Error("Expected change", s, err)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package lib
+
+import "strings"
+
+// Print converts a syntax tree back into its compact form.  Array
+// elements are always enclosed in square brackets and special
+// characters are always escaped.  Print(Parse(s)) is the same as s
+// if s is already in this canonical form.
+func Print(n Node) string {
+	var b strings.Builder
+	printNode(&b, n)
+	return b.String()
+}
+
+func printNode(b *strings.Builder, n Node) {
+	switch n := n.(type) {
+	case *String:
+		b.WriteString(escape(n.Text))
+	case *Array:
+		for _, elem := range n.Elems {
+			b.WriteString("[")
+			printNode(b, elem)
+			b.WriteString("]")
+		}
+	case *Map:
+		b.WriteString("{")
+		for kk, e := range n.Entries {
+			if kk > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString(escape(e.Key) + ":")
+			printNode(b, e.Value)
+		}
+		b.WriteString("}")
+	case *Splice:
+		printAll(b, n.Left, "(", n.Before, "=", n.After, ")", n.Right)
+	case *Move:
+		if n.Backward {
+			printAll(b, n.Left, "=", n.Skip, "(", n.Mid, ")", n.Right)
+		} else {
+			printAll(b, n.Left, "(", n.Mid, ")", n.Skip, "=", n.Right)
+		}
+	case *Range:
+		printAll(b, n.Left, "(", n.Elems, "=", n.Op, ")", n.Right)
+	case *Path:
+		printAll(b, n.Left, "(", n.Elem, ")", n.Right)
+	case *Set:
+		printAll(b, n.Map, "+", n.Changes)
+	default:
+		panic(&EncodeError{n})
+	}
+}
+
+func printAll(b *strings.Builder, parts ...interface{}) {
+	for _, part := range parts {
+		if s, ok := part.(string); ok {
+			b.WriteString(s)
+		} else {
+			printNode(b, part.(Node))
+		}
+	}
+}
+
+// escape prefixes all special characters in s with a backslash
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(specials, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,139 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+)
+
+// Ranges implements a bunch of useful utilities for working with
+// range operations on an array of strings.  The operation applied to
+// every element of the range is either a splice (inserting one of
+// Inserts) or a move within the element.
+//
+// The same operation is applied to every element of the range, so an
+// operation is only generated for a range if it fits every element:
+// a splice must remove the same text from all of them and a move
+// must be within the shortest of them.  Operations that have no
+// effect (such as moves of no elements) are skipped.
+type Ranges struct {
+	Input   []string
+	Inserts []string
+}
+
+// ForEach generates all possible range operations for the given
+// input array and calls the provided callback with the op encoded
+// as in this spec:
+// http://github.com/dotchain/dataset/CompactJSON.md
+func (r *Ranges) ForEach(fn func(string)) {
+	for offset := 0; offset < len(r.Input); offset++ {
+		for end := offset + 1; end <= len(r.Input); end++ {
+			r.forEachOp(r.Input[offset:end], func(op changes.Change) {
+				fn(r.EncodeCompact(offset, end-offset, op))
+			})
+		}
+	}
+}
+
+func (r *Ranges) forEachOp(elems []string, fn func(op changes.Change)) {
+	shortest := elems[0]
+	for _, elem := range elems {
+		if len(elem) < len(shortest) {
+			shortest = elem
+		}
+	}
+
+	for offset := 0; offset <= len(shortest); offset++ {
+		for end := offset; end <= len(shortest); end++ {
+			before := shortest[offset:end]
+			if !r.allHave(elems, offset, before) {
+				continue
+			}
+			for _, insert := range r.Inserts {
+				if insert != before {
+					fn(changes.Splice{Offset: offset, Before: types.S16(before), After: types.S16(insert)})
+				}
+			}
+		}
+	}
+
+	for offset := 0; offset < len(shortest); offset++ {
+		for end := offset + 1; end <= len(shortest); end++ {
+			for dest := 0; dest <= len(shortest); dest++ {
+				if dest < offset {
+					fn(changes.Move{Offset: offset, Count: end - offset, Distance: dest - offset})
+				} else if dest > end {
+					fn(changes.Move{Offset: offset, Count: end - offset, Distance: dest - end})
+				}
+			}
+		}
+	}
+}
+
+func (r *Ranges) allHave(elems []string, offset int, s string) bool {
+	for _, elem := range elems {
+		if elem[offset:offset+len(s)] != s {
+			return false
+		}
+	}
+	return true
+}
+
+// EncodeCompact encodes a range operation applying op to count
+// elements starting at offset into a compact format
+func (r *Ranges) EncodeCompact(offset, count int, op changes.Change) string {
+	cs := changes.ChangeSet{}
+	for kk := 0; kk < count; kk++ {
+		cs = append(cs, changes.PathChange{Path: []interface{}{offset + kk}, Change: op})
+	}
+	return Compact{}.Encode1(r.input(), cs)
+}
+
+// input returns the compact form of the input array
+func (r *Ranges) input() string {
+	input := types.A{}
+	for _, elem := range r.Input {
+		input = append(input, types.S16(elem))
+	}
+	return Compact{}.EncodeValue(input)
+}
+
+// ForEachPair generates pairs of operations
+func (r *Ranges) ForEachPair(fn func(left, right string)) {
+	r.ForEach(func(s1 string) {
+		r.ForEach(func(s2 string) {
+			fn(s1, s2)
+		})
+	})
+}
+
+// ForEachUniquePair generates only unique pairs of operations and
+// uses the provided alphabet for the "uniqueness" calculation.
+//
+// Normalize does not support repeated characters which are common
+// with ranges (the elements are often the same), so the characters
+// of each pair are mapped to the alphabet with rename instead.  An
+// error is returned if the alphabet has too few letters.
+func (r *Ranges) ForEachUniquePair(alphabet []string, fn func(string, string, string)) error {
+	input := r.input()
+	seen := map[string]bool{}
+	var err error
+	r.ForEachPair(func(s1, s2 string) {
+		var renamed []string
+		if err != nil {
+			return
+		}
+		if renamed, err = rename(alphabet, input, s1, s2); err != nil {
+			return
+		}
+		key := renamed[1] + "|||" + renamed[2]
+		if !seen[key] {
+			seen[key] = true
+			fn(renamed[0], renamed[1], renamed[2])
+		}
+	})
+	return err
+}
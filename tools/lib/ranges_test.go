@@ -0,0 +1,81 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package lib_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dotchain/dataset/tools/lib"
+	"github.com/dotchain/dot/changes"
+)
+
+func ExampleRanges_ForEachUniquePair() {
+	r := lib.Ranges{Input: []string{"ab", "ab", "ab"}, Inserts: []string{"", "x"}}
+	alphabet := strings.Split("abcdefghijklmnopqrstuvwxyz", "")
+	seen := map[string]bool{}
+	r.ForEachUniquePair(alphabet, func(i, l, r string) {
+		if seen[i+"-->"+l+r] {
+			fmt.Println("Unexpected duplicate")
+		}
+		seen[i+"-->"+l+r] = true
+	})
+	fmt.Println("Number of unique pairs =", len(seen))
+
+	// Output: Number of unique pairs = 4356
+}
+
+func TestRangesForEach(t *testing.T) {
+	c := lib.Compact{}
+	r := lib.Ranges{Input: []string{"ab", "ac", "abc"}, Inserts: []string{"", "x"}}
+	seen := map[string]bool{}
+	r.ForEach(func(op string) {
+		if seen[op] {
+			t.Error("Duplicate", op)
+		}
+		seen[op] = true
+
+		input, ch, err := c.DecodeE(op)
+		if err != nil {
+			t.Fatal(op, err)
+		}
+		if x := c.EncodeValue(input); x != "[ab][ac][abc]" {
+			t.Error("Unexpected input", op, x)
+		}
+		cs, ok := ch.(changes.ChangeSet)
+		if !ok || len(cs) == 0 {
+			t.Fatal("Unexpected change", op)
+		}
+
+		// splices must remove the same text from every element
+		for _, cx := range cs {
+			p := cx.(changes.PathChange)
+			splice, ok := p.Change.(changes.Splice)
+			if !ok {
+				continue
+			}
+			elem := r.Input[p.Path[0].(int)]
+			before := string(c.EncodeValue(splice.Before))
+			if elem[splice.Offset:splice.Offset+len(before)] != before {
+				t.Error("Mismatched splice", op)
+			}
+		}
+	})
+
+	if !seen["[ab][ac]([abc]=(a=)bc)"] || seen["[ab]([ac][abc]=a(b=)c)"] || len(seen) == 0 {
+		t.Error("Unexpected ops", len(seen))
+	}
+}
+
+func TestRangesForEachUniquePairShortAlphabet(t *testing.T) {
+	r := lib.Ranges{Input: []string{"ab", "ab"}, Inserts: []string{"", "x"}}
+	err := r.ForEachUniquePair([]string{"a"}, func(i, l, r string) {
+		t.Error("Unexpected pair", i, l, r)
+	})
+	if err == nil {
+		t.Error("Expected an error")
+	}
+}
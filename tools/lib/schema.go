@@ -0,0 +1,242 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a JSON Schema document.  Only the subset of the
+// specification used by the schemas of this repository is
+// supported: type, const, enum, properties, required,
+// additionalProperties, items, prefixItems, minItems, maxItems,
+// minLength, maxLength, minimum, maximum, anyOf, oneOf and $ref to
+// "#/$defs/...".  All other keywords are ignored.
+type Schema struct {
+	root interface{}
+}
+
+// SchemaError is the error returned when a value does not match a
+// schema.  Path is a JSON pointer to the offending value.
+type SchemaError struct {
+	Path, Message string
+}
+
+// Error implements the error interface
+func (e *SchemaError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Sprintf("schema: %s: %s", path, e.Message)
+}
+
+// ParseSchema parses a JSON Schema document
+func ParseSchema(data []byte) (*Schema, error) {
+	var root interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	return &Schema{root}, nil
+}
+
+// Validate checks that the value (as decoded by encoding/json into
+// an interface{}) matches the schema.  It returns a *SchemaError
+// for the first mismatch found.
+func (s *Schema) Validate(v interface{}) error {
+	return s.validate(s.root, v, "")
+}
+
+func (s *Schema) validate(schema, v interface{}, path string) error {
+	fail := func(format string, args ...interface{}) error {
+		return &SchemaError{path, fmt.Sprintf(format, args...)}
+	}
+
+	if b, ok := schema.(bool); ok {
+		if !b {
+			return fail("not allowed")
+		}
+		return nil
+	}
+	m, ok := schema.(map[string]interface{})
+	if !ok {
+		return fail("invalid schema %v", schema)
+	}
+
+	if ref, ok := m["$ref"]; ok {
+		def, err := s.resolve(ref)
+		if err != nil {
+			return fail("%v", err)
+		}
+		if err := s.validate(def, v, path); err != nil {
+			return err
+		}
+	}
+
+	if t, ok := m["type"].(string); ok && !s.isType(t, v) {
+		return fail("expected %s", t)
+	}
+	if c, ok := m["const"]; ok && !reflect.DeepEqual(c, v) {
+		return fail("expected %v", c)
+	}
+	if enum, ok := m["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			found = found || reflect.DeepEqual(e, v)
+		}
+		if !found {
+			return fail("expected one of %v", enum)
+		}
+	}
+
+	if n, ok := v.(float64); ok {
+		if min, ok := m["minimum"].(float64); ok && n < min {
+			return fail("expected at least %v", min)
+		}
+		if max, ok := m["maximum"].(float64); ok && n > max {
+			return fail("expected at most %v", max)
+		}
+	}
+
+	if str, ok := v.(string); ok {
+		n := float64(utf8.RuneCountInString(str))
+		if min, ok := m["minLength"].(float64); ok && n < min {
+			return fail("expected at least %v characters", min)
+		}
+		if max, ok := m["maxLength"].(float64); ok && n > max {
+			return fail("expected at most %v characters", max)
+		}
+	}
+
+	if obj, ok := v.(map[string]interface{}); ok {
+		if err := s.validateObject(m, obj, path); err != nil {
+			return err
+		}
+	}
+	if arr, ok := v.([]interface{}); ok {
+		if err := s.validateArray(m, arr, path); err != nil {
+			return err
+		}
+	}
+
+	if anyOf, ok := m["anyOf"].([]interface{}); ok && s.matches(anyOf, v, path) == 0 {
+		return fail("does not match any of the alternatives")
+	}
+	if oneOf, ok := m["oneOf"].([]interface{}); ok && s.matches(oneOf, v, path) != 1 {
+		return fail("does not match exactly one of the alternatives")
+	}
+	return nil
+}
+
+func (s *Schema) validateObject(m, obj map[string]interface{}, path string) error {
+	if required, ok := m["required"].([]interface{}); ok {
+		for _, key := range required {
+			if _, ok := obj[fmt.Sprint(key)]; !ok {
+				return &SchemaError{path, fmt.Sprintf("missing %q", key)}
+			}
+		}
+	}
+
+	props, _ := m["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		child := path + "/" + s.escape(key)
+		if prop, ok := props[key]; ok {
+			if err := s.validate(prop, obj[key], child); err != nil {
+				return err
+			}
+		} else if extra, ok := m["additionalProperties"]; ok {
+			if err := s.validate(extra, obj[key], child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateArray(m map[string]interface{}, arr []interface{}, path string) error {
+	if min, ok := m["minItems"].(float64); ok && float64(len(arr)) < min {
+		return &SchemaError{path, fmt.Sprintf("expected at least %v items", min)}
+	}
+	if max, ok := m["maxItems"].(float64); ok && float64(len(arr)) > max {
+		return &SchemaError{path, fmt.Sprintf("expected at most %v items", max)}
+	}
+
+	prefix, _ := m["prefixItems"].([]interface{})
+	for kk, elem := range arr {
+		child := path + "/" + strconv.Itoa(kk)
+		var err error
+		if kk < len(prefix) {
+			err = s.validate(prefix[kk], elem, child)
+		} else if items, ok := m["items"]; ok {
+			err = s.validate(items, elem, child)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matches returns the number of schemas that v matches
+func (s *Schema) matches(schemas []interface{}, v interface{}, path string) int {
+	count := 0
+	for _, schema := range schemas {
+		if s.validate(schema, v, path) == nil {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *Schema) isType(t string, v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return t == "null"
+	case bool:
+		return t == "boolean"
+	case string:
+		return t == "string"
+	case float64:
+		return t == "number" || t == "integer" && v == math.Trunc(v)
+	case []interface{}:
+		return t == "array"
+	case map[string]interface{}:
+		return t == "object"
+	}
+	return false
+}
+
+func (s *Schema) resolve(ref interface{}) (interface{}, error) {
+	str, _ := ref.(string)
+	if !strings.HasPrefix(str, "#/$defs/") {
+		return nil, fmt.Errorf("unsupported $ref %v", ref)
+	}
+
+	root, _ := s.root.(map[string]interface{})
+	defs, _ := root["$defs"].(map[string]interface{})
+	def, ok := defs[strings.TrimPrefix(str, "#/$defs/")]
+	if !ok {
+		return nil, fmt.Errorf("unknown $ref %v", ref)
+	}
+	return def, nil
+}
+
+// escape escapes a key as a JSON pointer segment
+func (s *Schema) escape(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
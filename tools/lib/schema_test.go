@@ -0,0 +1,110 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package lib_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/dotchain/dataset/tools/lib"
+)
+
+func TestSchemaValidate(t *testing.T) {
+	schema, err := lib.ParseSchema([]byte(`{
+		"type": "object",
+		"properties": {
+			"format": {"const": "x"},
+			"count": {"type": "integer", "minimum": 0, "maximum": 10},
+			"kind": {"enum": ["a", "b"]},
+			"row": {"$ref": "#/$defs/row"},
+			"name": {"type": "string", "minLength": 1, "maxLength": 2}
+		},
+		"required": ["format"],
+		"additionalProperties": false,
+		"oneOf": [{"required": ["count"]}, {"required": ["kind"]}],
+		"$defs": {
+			"row": {
+				"type": "array",
+				"prefixItems": [{"type": "string"}],
+				"items": {"type": "number"},
+				"minItems": 1,
+				"maxItems": 3
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		json, path string
+	}{
+		{`{"format": "x", "count": 5}`, ""},
+		{`{"format": "x", "kind": "b", "row": ["a", 1, 2.5]}`, ""},
+		{`{"format": "x", "kind": "a", "name": "𝐀𝐁"}`, ""},
+		{`[]`, "/"},
+		{`{"count": 5}`, "/"},
+		{`{"format": "y", "count": 5}`, "/format"},
+		{`{"format": "x", "count": 5.5}`, "/count"},
+		{`{"format": "x", "count": 11}`, "/count"},
+		{`{"format": "x", "kind": "c"}`, "/kind"},
+		{`{"format": "x", "count": 1, "kind": "a"}`, "/"},
+		{`{"format": "x"}`, "/"},
+		{`{"format": "x", "count": 1, "other": 1}`, "/other"},
+		{`{"format": "x", "count": 1, "row": []}`, "/row"},
+		{`{"format": "x", "count": 1, "row": [1]}`, "/row/0"},
+		{`{"format": "x", "count": 1, "row": ["a", "b"]}`, "/row/1"},
+		{`{"format": "x", "count": 1, "row": ["a", 1, 2, 3]}`, "/row"},
+		{`{"format": "x", "count": 1, "name": ""}`, "/name"},
+		{`{"format": "x", "count": 1, "name": "abc"}`, "/name"},
+	}
+
+	for _, test := range tests {
+		var v interface{}
+		if err := json.Unmarshal([]byte(test.json), &v); err != nil {
+			t.Fatal(err)
+		}
+		err := schema.Validate(v)
+		if test.path == "" && err != nil {
+			t.Error("Unexpected error", test.json, err)
+		}
+		if test.path != "" {
+			if se, ok := err.(*lib.SchemaError); !ok || se.Path != test.path && (se.Path != "" || test.path != "/") {
+				t.Error("Unexpected error", test.json, err)
+			}
+		}
+	}
+}
+
+func TestSchemaFiles(t *testing.T) {
+	files := map[string]string{
+		"../../schema/compact.schema.json":          "../../json/compact/splices.json",
+		"../../schema/journal_suite.schema.json":    "../../json/journal_suite.json",
+		"../../schema/triples.schema.json":          "../../json/triples.json",
+		"../../schema/triples_failures.schema.json": "../../json/triples_failures.json",
+	}
+	for schemaFile, file := range files {
+		data, err := ioutil.ReadFile(schemaFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		schema, err := lib.ParseSchema(data)
+		if err != nil {
+			t.Fatal(schemaFile, err)
+		}
+
+		var v interface{}
+		if data, err = ioutil.ReadFile(file); err != nil {
+			t.Fatal(err)
+		}
+		if err = json.Unmarshal(data, &v); err != nil {
+			t.Fatal(err)
+		}
+		if err := schema.Validate(v); err != nil {
+			t.Error(file, err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package lib
+
+import "strings"
+
+// Sequences implements a bunch of useful utilities for working with
+// short sequences of splices and moves on a string, each operation
+// applying to the output of the previous one.  The lengths of the
+// sequences are taken from Lengths.
+//
+// Operations that leave the string unchanged are skipped as the
+// sequence would then have the same effect as a shorter one.  So are
+// repeated operations at the same step (the moves of a string can
+// have the same compact form).
+type Sequences struct {
+	Input   string
+	Inserts []string
+	Lengths []int
+}
+
+// ForEach generates all the sequences of operations for the given
+// input and calls the provided callback with each sequence encoded
+// as in this spec:
+// http://github.com/dotchain/dataset/CompactJSON.md
+func (s *Sequences) ForEach(fn func(seq []string)) {
+	for _, length := range s.Lengths {
+		s.forEach(s.Input, nil, length, fn)
+	}
+}
+
+func (s *Sequences) forEach(input string, seq []string, length int, fn func([]string)) {
+	if len(seq) == length {
+		fn(append([]string(nil), seq...))
+		return
+	}
+
+	c := Compact{}
+	seen := map[string]bool{}
+	ops := Enumerators{&Splices{Input: input, Inserts: s.Inserts}, &Moves{Input: input}}
+	ops.ForEach(func(op string) {
+		if seen[op] {
+			return
+		}
+		seen[op] = true
+		_, ch := c.Decode(op)
+		if output := c.Apply(input, ch); output != input {
+			s.forEach(output, append(seq, op), length, fn)
+		}
+	})
+}
+
+// ForEachPair generates pairs of sequences
+func (s *Sequences) ForEachPair(fn func(left, right []string)) {
+	all := [][]string{}
+	s.ForEach(func(seq []string) {
+		all = append(all, seq)
+	})
+	for _, left := range all {
+		for _, right := range all {
+			fn(left, right)
+		}
+	}
+}
+
+// ForEachUniquePair generates only unique pairs of sequences and
+// uses the provided alphabet for the "uniqueness" calculation.  The
+// sequences repeat characters of the input, so the characters of each
+// pair are mapped to the alphabet with rename instead of Normalize.
+// An error is returned if the alphabet has too few letters.
+func (s *Sequences) ForEachUniquePair(alphabet []string, fn func(input string, left, right []string)) error {
+	seen := map[string]bool{}
+	var err error
+	s.ForEachPair(func(s1, s2 []string) {
+		var renamed []string
+		if err != nil {
+			return
+		}
+		all := append(append([]string{s.Input}, s1...), s2...)
+		if renamed, err = rename(alphabet, all...); err != nil {
+			return
+		}
+		left, right := renamed[1:1+len(s1)], renamed[1+len(s1):]
+		key := strings.Join(left, " ") + "|||" + strings.Join(right, " ")
+		if !seen[key] {
+			seen[key] = true
+			fn(renamed[0], left, right)
+		}
+	})
+	return err
+}
@@ -0,0 +1,72 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package lib_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dotchain/dataset/tools/lib"
+)
+
+func ExampleSequences_ForEachUniquePair() {
+	s := lib.Sequences{Input: "ab", Inserts: []string{""}, Lengths: []int{2, 3}}
+	alphabet := strings.Split("abcdefghijklmnopqrstuvwxyz", "")
+	seen := map[string]bool{}
+	s.ForEachUniquePair(alphabet, func(i string, l, r []string) {
+		key := i + "-->" + strings.Join(l, " ") + "|" + strings.Join(r, " ")
+		if seen[key] {
+			fmt.Println("Unexpected duplicate")
+		}
+		seen[key] = true
+	})
+	fmt.Println("Number of unique pairs =", len(seen))
+
+	// Output: Number of unique pairs = 1296
+}
+
+func TestSequencesForEach(t *testing.T) {
+	c := lib.Compact{}
+	s := lib.Sequences{Input: "ab", Inserts: []string{"", "x"}, Lengths: []int{2, 3}}
+	seen := map[string]bool{}
+	s.ForEach(func(seq []string) {
+		key := strings.Join(seq, " ")
+		if seen[key] {
+			t.Error("Duplicate", seq)
+		}
+		seen[key] = true
+
+		if len(seq) != 2 && len(seq) != 3 {
+			t.Error("Unexpected length", seq)
+		}
+		input, cs, err := c.DecodeSeq(seq)
+		if err != nil || input != "ab" {
+			t.Fatal("Unexpected", seq, input, err)
+		}
+
+		// every step must change the value
+		for kk := range cs {
+			before := c.Apply(input, cs[:kk])
+			if c.Apply(before, cs[kk]) == before {
+				t.Error("Unexpected no-op", seq, kk)
+			}
+		}
+	})
+
+	if !seen["(a=)b (b=x)"] || seen["(=)ab (a=)b"] || len(seen) == 0 {
+		t.Error("Unexpected sequences", len(seen))
+	}
+}
+
+func TestSequencesForEachUniquePairShortAlphabet(t *testing.T) {
+	s := lib.Sequences{Input: "ab", Inserts: []string{"", "x"}, Lengths: []int{2}}
+	err := s.ForEachUniquePair([]string{"a"}, func(i string, l, r []string) {
+		t.Error("Unexpected pair", i, l, r)
+	})
+	if err == nil {
+		t.Error("Expected an error")
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"sort"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+)
+
+// Sets implements a bunch of useful utilities for working with
+// changes to a map of strings.  The changes are to a single key
+// from Keys: adding a key that is not in the input, replacing the
+// value of a key that is or deleting it.  The new values are taken
+// from Values which cannot have empty strings as the compact form
+// uses those for deletions.
+type Sets struct {
+	Input  map[string]string
+	Keys   []string
+	Values []string
+}
+
+// ForEach generates all possible set operations for the given input
+// map and calls the provided callback with the op encoded as in this
+// spec:
+// http://github.com/dotchain/dataset/CompactJSON.md
+func (s *Sets) ForEach(fn func(string)) {
+	keys := append([]string(nil), s.Keys...)
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		before, ok := s.Input[key]
+		if ok {
+			fn(s.EncodeCompact(key, types.S16(before), changes.Nil))
+		}
+		for _, after := range s.Values {
+			switch {
+			case !ok:
+				fn(s.EncodeCompact(key, changes.Nil, types.S16(after)))
+			case after != before:
+				fn(s.EncodeCompact(key, types.S16(before), types.S16(after)))
+			}
+		}
+	}
+}
+
+// EncodeCompact encodes the change of the value of a key into a
+// compact format.  Before or after is changes.Nil if the key is
+// being added or deleted respectively.
+func (s *Sets) EncodeCompact(key string, before, after changes.Value) string {
+	replace := changes.Replace{Before: before, After: after}
+	return Compact{}.Encode1(s.input(), changes.PathChange{Path: []interface{}{key}, Change: replace})
+}
+
+// input returns the compact form of the input map
+func (s *Sets) input() string {
+	input := types.M{}
+	for key, value := range s.Input {
+		input[key] = types.S16(value)
+	}
+	return Compact{}.EncodeValue(input)
+}
+
+// ForEachPair generates pairs of operations
+func (s *Sets) ForEachPair(fn func(left, right string)) {
+	s.ForEach(func(s1 string) {
+		s.ForEach(func(s2 string) {
+			fn(s1, s2)
+		})
+	})
+}
+
+// ForEachUniquePair generates only unique pairs of operations and
+// uses the provided alphabet for the "uniqueness" calculation.  Keys
+// and values repeat within a set operation, so the characters are
+// mapped to the alphabet with rename instead of Normalize.  An error
+// is returned if the alphabet has too few letters.
+func (s *Sets) ForEachUniquePair(alphabet []string, fn func(string, string, string)) error {
+	input := s.input()
+	seen := map[string]bool{}
+	var err error
+	s.ForEachPair(func(s1, s2 string) {
+		var renamed []string
+		if err != nil {
+			return
+		}
+		if renamed, err = rename(alphabet, input, s1, s2); err != nil {
+			return
+		}
+		key := renamed[1] + "|||" + renamed[2]
+		if !seen[key] {
+			seen[key] = true
+			fn(renamed[0], renamed[1], renamed[2])
+		}
+	})
+	return err
+}
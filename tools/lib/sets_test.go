@@ -0,0 +1,92 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package lib_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dotchain/dataset/tools/lib"
+)
+
+func ExampleSets_ForEachUniquePair() {
+	s := lib.Sets{
+		Input:  map[string]string{"a": "x", "b": "y"},
+		Keys:   []string{"a", "b", "c"},
+		Values: []string{"x", "y", "z"},
+	}
+	alphabet := strings.Split("abcdefghijklmnopqrstuvwxyz", "")
+	seen := map[string]bool{}
+	s.ForEachUniquePair(alphabet, func(i, l, r string) {
+		if seen[i+"-->"+l+r] {
+			fmt.Println("Unexpected duplicate")
+		}
+		seen[i+"-->"+l+r] = true
+	})
+	fmt.Println("Number of unique pairs =", len(seen))
+
+	// Output: Number of unique pairs = 81
+}
+
+func TestSetsForEach(t *testing.T) {
+	s := lib.Sets{
+		Input:  map[string]string{"a": "x"},
+		Keys:   []string{"b", "a"},
+		Values: []string{"x", "y"},
+	}
+	ops := []string{}
+	s.ForEach(func(op string) {
+		ops = append(ops, op)
+	})
+
+	expected := []string{"{a:x}+{a:}", "{a:x}+{a:y}", "{a:x}+{b:x}", "{a:x}+{b:y}"}
+	if !reflect.DeepEqual(ops, expected) {
+		t.Error("Unexpected ops", ops)
+	}
+
+	c := lib.Compact{}
+	for _, op := range ops {
+		if input, _ := c.Decode(op); input != "{a:x}" {
+			t.Error("Unexpected input", op, input)
+		}
+	}
+}
+
+func TestSetsForEachUniquePairSortsKeys(t *testing.T) {
+	// "b" is renamed to "a" and "a" to "b", which swaps the keys
+	s := lib.Sets{
+		Input:  map[string]string{"ba": "x", "bb": "y"},
+		Keys:   []string{"ba", "bb"},
+		Values: []string{"z"},
+	}
+	alphabet := strings.Split("abcdefghijklmnopqrstuvwxyz", "")
+	c := lib.Compact{}
+	err := s.ForEachUniquePair(alphabet, func(i, l, r string) {
+		for _, op := range []string{l, r} {
+			if input, ch := c.Decode(op); input != i || c.Encode1(input, ch) != op {
+				t.Error("Unexpected op", i, op)
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetsForEachUniquePairShortAlphabet(t *testing.T) {
+	s := lib.Sets{
+		Input:  map[string]string{"a": "x", "b": "y"},
+		Keys:   []string{"a", "b"},
+		Values: []string{"z"},
+	}
+	err := s.ForEachUniquePair([]string{"a", "b"}, func(i, l, r string) {
+		t.Error("Unexpected pair", i, l, r)
+	})
+	if err == nil {
+		t.Error("Expected an error")
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dotchain/dot/changes"
+)
+
+// Triple is three concurrent operations on the same input, each
+// encoded as in this spec:
+// http://github.com/dotchain/dataset/CompactJSON.md
+type Triple [3]string
+
+// TripleOrders are all the orders in which the operations of a
+// triple can be merged, with "a", "b" and "c" referring to the
+// operations of the triple.  For example, "bca" applies the second
+// operation, then the third transformed against it and finally the
+// first transformed against both.
+var TripleOrders = []string{"abc", "acb", "bac", "bca", "cab", "cba"}
+
+// Merge merges the operations of the triple in every order of
+// TripleOrders.  It returns the operations applied for each order
+// (each applying to the output of the previous one) and the
+// resulting outputs.  The outputs must all be the same for the merge
+// to converge.
+//
+// The operation earlier in the triple is always passed as the left
+// side of the merge, so conflicts are resolved in its favor whatever
+// the order.
+func (t Triple) Merge() (orders map[string][]string, outputs map[string]string, err error) {
+	return t.MergeWith(changes.Merge)
+}
+
+// MergeWith is like Merge but uses the provided merge function
+// instead of changes.Merge
+func (t Triple) MergeWith(merge func(l, r changes.Change) (changes.Change, changes.Change)) (orders map[string][]string, outputs map[string]string, err error) {
+	c := Compact{}
+	input, ops := "", [3]changes.Change{}
+	for kk, op := range t {
+		v, ch, err := c.DecodeE(op)
+		if err != nil {
+			return nil, nil, err
+		}
+		if kk > 0 && c.EncodeValue(v) != input {
+			return nil, nil, fmt.Errorf("triple: %q does not apply to %q", op, input)
+		}
+		input, ops[kk] = c.EncodeValue(v), ch
+	}
+
+	orders, outputs = map[string][]string{}, map[string]string{}
+	for _, order := range TripleOrders {
+		seq := changes.ChangeSet{}
+		for kk := range order {
+			x := ops[order[kk]-'a']
+			for jj, applied := range seq {
+				if order[jj] < order[kk] {
+					x, _ = merge(applied, x)
+				} else {
+					_, x = merge(x, applied)
+				}
+			}
+			seq = append(seq, x)
+		}
+
+		encoded, err := c.EncodeE(input, seq)
+		if err != nil {
+			return nil, nil, err
+		}
+		orders[order], outputs[order] = encoded, c.Apply(input, seq)
+	}
+	return orders, outputs, nil
+}
+
+// ForEachUniqueTriple generates the unique triples of operations of
+// the enumerator that apply to the same input and uses the provided
+// alphabet for the "uniqueness" calculation (see
+// ForEachUniqueCrossPair).  As triples are merged in all orders, only
+// one permutation of the operations is generated for each triple.  An
+// error is returned if the alphabet has too few letters.
+func ForEachUniqueTriple(alphabet []string, e Enumerator, fn func(input string, t Triple)) error {
+	c := Compact{}
+	inputs, ops := []string{}, map[string][]string{}
+	e.ForEach(func(op string) {
+		input, _ := c.Decode(op)
+		if _, ok := ops[input]; !ok {
+			inputs = append(inputs, input)
+		}
+		ops[input] = append(ops[input], op)
+	})
+
+	seen := map[string]bool{}
+	for _, input := range inputs {
+		x := ops[input]
+		for i := range x {
+			for j := i; j < len(x); j++ {
+				for k := j; k < len(x); k++ {
+					renamed, err := rename(alphabet, input, x[i], x[j], x[k])
+					if err != nil {
+						return err
+					}
+					t := Triple{renamed[1], renamed[2], renamed[3]}
+					key := strings.Join(t[:], "|||")
+					if !seen[key] {
+						seen[key] = true
+						fn(renamed[0], t)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package lib_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dotchain/dataset/tools/lib"
+)
+
+func ExampleForEachUniqueTriple() {
+	e := lib.Enumerators{
+		&lib.Splices{Input: "ab", Inserts: []string{"", "x"}},
+		&lib.Moves{Input: "ab"},
+	}
+	alphabet := strings.Split("abcdefghijklmnopqrstuvwxyz", "")
+	seen := map[string]bool{}
+	lib.ForEachUniqueTriple(alphabet, e, func(input string, t lib.Triple) {
+		key := input + "-->" + strings.Join(t[:], " ")
+		if seen[key] {
+			fmt.Println("Unexpected duplicate")
+		}
+		seen[key] = true
+	})
+	fmt.Println("Number of unique triples =", len(seen))
+
+	// Output: Number of unique triples = 3276
+}
+
+func TestTripleMerge(t *testing.T) {
+	triple := lib.Triple{"(a=)bc", "a(b=x)c", "ab(c=y)"}
+	orders, outputs, err := triple.Merge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, order := range lib.TripleOrders {
+		if outputs[order] != "xy" || len(orders[order]) != 3 {
+			t.Error("Unexpected", order, orders[order], outputs[order])
+		}
+	}
+	if x := orders["bca"]; x[0] != "a(b=x)c" || x[1] != "ax(c=y)" || x[2] != "(a=)xy" {
+		t.Error("Unexpected bca", x)
+	}
+
+	invalid := []lib.Triple{
+		{"(a=)bc", "a(b=x)c", "a(b=y)"},
+		{"(a=)bc", "a(b=x)c", "a(b=y"},
+	}
+	for _, triple := range invalid {
+		if _, _, err := triple.Merge(); err == nil {
+			t.Error("Unexpected success", triple)
+		}
+	}
+}
+
+func TestForEachUniqueTripleShortAlphabet(t *testing.T) {
+	e := &lib.Splices{Input: "ab", Inserts: []string{"", "x"}}
+	err := lib.ForEachUniqueTriple([]string{"a"}, e, func(input string, t1 lib.Triple) {
+		t.Error("Unexpected triple", input, t1)
+	})
+	if err == nil {
+		t.Error("Expected an error")
+	}
+}
+
+func TestTripleMergeTies(t *testing.T) {
+	triple := lib.Triple{"(=x)ab", "(=y)ab", "(a=)b"}
+	orders, outputs, err := triple.Merge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, order := range lib.TripleOrders {
+		if outputs[order] != "xyb" {
+			t.Error("Unexpected", order, orders[order], outputs[order])
+		}
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// SuiteHeader is the header of a test suite.  Please see
+// CompactJSON.md
+type SuiteHeader struct {
+	Format    string      `json:"format"`
+	Version   int         `json:"version"`
+	Generator string      `json:"generator,omitempty"`
+	Params    interface{} `json:"params,omitempty"`
+}
+
+// SuiteWriter writes a test suite as the tests are generated.  The
+// header is written first, followed by the tests and finally the
+// count of tests when the writer is closed.
+//
+// The tests are either elements of an array (see Add) or entries of
+// an object keyed by name (see AddNamed) depending on how the writer
+// was created.
+//
+// A writer created with CreateSuite writes to a temporary file which
+// only replaces the actual file when the writer is closed, so a
+// failure midway never leaves a truncated suite behind.
+//
+// Suites can also be written as newline delimited JSON (see
+// NewNDJSONSuiteWriter) with the header on the first line, one test
+// per line and a final line with the count: {"count": N}
+type SuiteWriter struct {
+	w      *bufio.Writer
+	indent string
+	keyed  bool
+	ndjson bool
+	count  int
+	err    error
+
+	file *os.File
+	path string
+}
+
+// NewSuiteWriter creates a writer which writes to w.  The indent is
+// used for the top level fields and twice over for the tests.
+func NewSuiteWriter(w io.Writer, h SuiteHeader, indent string, keyed bool) *SuiteWriter {
+	s := &SuiteWriter{w: bufio.NewWriter(w), indent: indent, keyed: keyed}
+
+	s.write("{")
+	s.field("format", h.Format)
+	s.field("version", h.Version)
+	if h.Generator != "" {
+		s.field("generator", h.Generator)
+	}
+	if h.Params != nil {
+		s.field("params", h.Params)
+	}
+
+	if keyed {
+		s.write("\n" + indent + `"tests": {`)
+	} else {
+		s.write("\n" + indent + `"tests": [`)
+	}
+	return s
+}
+
+// NewNDJSONSuiteWriter creates a writer which writes a suite with
+// an array of tests to w as newline delimited JSON
+func NewNDJSONSuiteWriter(w io.Writer, h SuiteHeader) *SuiteWriter {
+	s := &SuiteWriter{w: bufio.NewWriter(w), ndjson: true}
+	if data, err := json.Marshal(h); err != nil {
+		s.fail(err)
+	} else {
+		s.write(string(data) + "\n")
+	}
+	return s
+}
+
+// CreateSuite creates a writer which writes to a temporary file in
+// the same directory as path.  Close renames it to path while Abort
+// removes it.
+func CreateSuite(path string, h SuiteHeader, indent string, keyed bool) (*SuiteWriter, error) {
+	return create(path, func(w io.Writer) *SuiteWriter {
+		return NewSuiteWriter(w, h, indent, keyed)
+	})
+}
+
+// CreateNDJSONSuite is like CreateSuite but writes newline delimited
+// JSON
+func CreateNDJSONSuite(path string, h SuiteHeader) (*SuiteWriter, error) {
+	return create(path, func(w io.Writer) *SuiteWriter {
+		return NewNDJSONSuiteWriter(w, h)
+	})
+}
+
+func create(path string, fn func(w io.Writer) *SuiteWriter) (*SuiteWriter, error) {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return nil, err
+	}
+	s := fn(f)
+	s.file, s.path = f, path
+	return s, nil
+}
+
+// Add adds a test to a suite with an array of tests.  The test is
+// encoded with json.Marshal unless it is a json.RawMessage in which
+// case it is written as is.
+func (s *SuiteWriter) Add(test interface{}) error {
+	if s.keyed {
+		return s.fail(errors.New("suite: Add called on a keyed suite"))
+	}
+	return s.add("", test)
+}
+
+// AddNamed adds a test to a suite with an object of tests keyed by
+// name.  The test is encoded as with Add.
+func (s *SuiteWriter) AddNamed(name string, test interface{}) error {
+	if !s.keyed {
+		return s.fail(errors.New("suite: AddNamed called on an array suite"))
+	}
+	key, err := json.Marshal(name)
+	if err != nil {
+		return s.fail(err)
+	}
+	return s.add(string(key)+": ", test)
+}
+
+// Count returns the number of tests added so far
+func (s *SuiteWriter) Count() int {
+	return s.count
+}
+
+// Close writes the count of tests and completes the suite.  If the
+// writer was created with CreateSuite, the suite is moved to its
+// final location.  If any error happened earlier, the suite is
+// aborted and the error is returned.
+func (s *SuiteWriter) Close() error {
+	if s.err != nil {
+		s.Abort()
+		return s.err
+	}
+
+	switch {
+	case s.ndjson:
+		s.write(fmt.Sprintf("{\"count\":%d}\n", s.count))
+	case s.keyed:
+		s.write(fmt.Sprintf("\n%s},\n%s\"count\": %d\n}\n", s.indent, s.indent, s.count))
+	default:
+		s.write(fmt.Sprintf("\n%s],\n%s\"count\": %d\n}\n", s.indent, s.indent, s.count))
+	}
+	if s.err == nil {
+		s.fail(s.w.Flush())
+	}
+	if s.file == nil || s.err != nil {
+		return s.closeErr()
+	}
+
+	s.fail(s.file.Chmod(0644))
+	s.fail(s.file.Close())
+	if s.err == nil && s.fail(os.Rename(s.file.Name(), s.path)) == nil {
+		s.file = nil
+	}
+	return s.closeErr()
+}
+
+// Abort discards the suite if it was created with CreateSuite
+func (s *SuiteWriter) Abort() {
+	if s.file != nil {
+		s.file.Close()
+		os.Remove(s.file.Name())
+		s.file = nil
+	}
+	if s.err == nil {
+		s.err = errors.New("suite: aborted")
+	}
+}
+
+func (s *SuiteWriter) closeErr() error {
+	if s.err != nil {
+		s.Abort()
+	}
+	return s.err
+}
+
+func (s *SuiteWriter) add(prefix string, test interface{}) error {
+	data, ok := test.(json.RawMessage)
+	if ok && !json.Valid(data) {
+		return s.fail(fmt.Errorf("suite: invalid JSON %q", data))
+	}
+	if !ok {
+		var err error
+		if data, err = json.Marshal(test); err != nil {
+			return s.fail(err)
+		}
+	}
+
+	if s.ndjson {
+		var b bytes.Buffer
+		if err := json.Compact(&b, data); err != nil {
+			return s.fail(err)
+		}
+		s.write(b.String() + "\n")
+	} else if s.count == 0 {
+		s.write("\n" + s.indent + s.indent + prefix + string(data))
+	} else {
+		s.write(",\n" + s.indent + s.indent + prefix + string(data))
+	}
+	s.count++
+	return s.err
+}
+
+func (s *SuiteWriter) field(name string, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		s.fail(err)
+		return
+	}
+	s.write(fmt.Sprintf("\n%s%q: %s,", s.indent, name, data))
+}
+
+func (s *SuiteWriter) write(str string) {
+	if s.err == nil {
+		_, s.err = s.w.WriteString(str)
+	}
+}
+
+// fail records the first error
+func (s *SuiteWriter) fail(err error) error {
+	if s.err == nil {
+		s.err = err
+	}
+	return s.err
+}
@@ -0,0 +1,161 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package lib_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dotchain/dataset/tools/lib"
+)
+
+func TestSuiteWriter(t *testing.T) {
+	var b strings.Builder
+	h := lib.SuiteHeader{Format: "compact", Version: 1, Generator: "test"}
+	w := lib.NewSuiteWriter(&b, h, "\t", false)
+	w.Add([]interface{}{"a", "b", []string{"a(=b)"}})
+	w.Add(json.RawMessage(`["c", "d"]`))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{
+	"format": "compact",
+	"version": 1,
+	"generator": "test",
+	"tests": [
+		["a","b",["a(=b)"]],
+		["c", "d"]
+	],
+	"count": 2
+}
+`
+	if b.String() != expected {
+		t.Errorf("Unexpected output %s", b.String())
+	}
+
+	b.Reset()
+	h = lib.SuiteHeader{Format: "journal_suite", Version: 1, Params: map[string]int{"x": 1}}
+	w = lib.NewSuiteWriter(&b, h, "  ", true)
+	w.AddNamed("first", map[string]int{"y": 2})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(b.String()), &v); err != nil {
+		t.Fatal(err, b.String())
+	}
+	expectedv := map[string]interface{}{
+		"format":  "journal_suite",
+		"version": 1.0,
+		"params":  map[string]interface{}{"x": 1.0},
+		"tests":   map[string]interface{}{"first": map[string]interface{}{"y": 2.0}},
+		"count":   1.0,
+	}
+	if !reflect.DeepEqual(v, expectedv) {
+		t.Errorf("Unexpected output %s", b.String())
+	}
+}
+
+func TestSuiteWriterErrors(t *testing.T) {
+	h := lib.SuiteHeader{Format: "compact", Version: 1}
+	tests := []func(w *lib.SuiteWriter){
+		func(w *lib.SuiteWriter) { w.AddNamed("x", 1) },
+		func(w *lib.SuiteWriter) { w.Add(json.RawMessage(`[1,`)) },
+		func(w *lib.SuiteWriter) { w.Add(func() {}) },
+	}
+	for _, test := range tests {
+		var b strings.Builder
+		w := lib.NewSuiteWriter(&b, h, "\t", false)
+		test(w)
+		if err := w.Close(); err == nil {
+			t.Error("Unexpected success", b.String())
+		}
+	}
+
+	var b strings.Builder
+	w := lib.NewSuiteWriter(&b, h, "\t", true)
+	if err := w.Add(1); err == nil {
+		t.Error("Unexpected success")
+	}
+}
+
+func TestCreateSuite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "suite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "suite.json")
+	if err := ioutil.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := lib.SuiteHeader{Format: "compact", Version: 1}
+	w, err := lib.CreateSuite(path, h, "\t", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Add([]string{"x"})
+	w.Abort()
+	if err := w.Close(); err == nil {
+		t.Error("Unexpected success")
+	}
+	if data, err := ioutil.ReadFile(path); err != nil || string(data) != "old" {
+		t.Error("Unexpected file", string(data), err)
+	}
+
+	if w, err = lib.CreateSuite(path, h, "\t", false); err != nil {
+		t.Fatal(err)
+	}
+	w.Add([]string{"x"})
+	w.Add(json.RawMessage("bad"))
+	if err := w.Close(); err == nil {
+		t.Error("Unexpected success")
+	}
+
+	if w, err = lib.CreateSuite(path, h, "\t", false); err != nil {
+		t.Fatal(err)
+	}
+	w.Add([]string{"x"})
+	if err := w.Close(); err != nil || w.Count() != 1 {
+		t.Fatal(err, w.Count())
+	}
+
+	var v map[string]interface{}
+	if data, err := ioutil.ReadFile(path); err != nil || json.Unmarshal(data, &v) != nil || v["count"] != 1.0 {
+		t.Error("Unexpected file", string(data), err)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Error("Unexpected files", len(files))
+	}
+}
+
+func TestNDJSONSuiteWriter(t *testing.T) {
+	var b strings.Builder
+	h := lib.SuiteHeader{Format: "compact", Version: 1, Generator: "test"}
+	w := lib.NewNDJSONSuiteWriter(&b, h)
+	w.Add([]interface{}{"a", "b", []string{"a(=b)"}})
+	w.Add(json.RawMessage("[\"c\",\n \"d\"]"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"format":"compact","version":1,"generator":"test"}
+["a","b",["a(=b)"]]
+["c","d"]
+{"count":2}
+`
+	if b.String() != expected {
+		t.Errorf("Unexpected output %s", b.String())
+	}
+}
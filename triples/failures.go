@@ -0,0 +1,253 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package triples
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+
+	"github.com/dotchain/dataset/suite"
+	"github.com/dotchain/dataset/tools/lib"
+	"github.com/dotchain/dot/changes"
+)
+
+// Failure is a triple whose orders do not converge with
+// changes.Merge, along with a minimal reproduction: two orders which
+// only differ in which of the first two operations is applied first.
+// The first two operations converge in both orders, but the third
+// operation transformed against them does not, which is a violation
+// of TP2 by changes.Merge.
+//
+// Orders and Outputs only have the two orders of the reproduction.
+// The failures document changes.Merge and are not tests: other merge
+// implementations may converge on these triples.
+type Failure struct {
+	Input   string
+	Triple  lib.Triple
+	Orders  map[string][]string
+	Outputs map[string]string
+}
+
+// Failures is a list of known failures.  The header fields are the
+// same as that of suite.Suite.
+type Failures struct {
+	Format    string          `json:"format"`
+	Version   int             `json:"version"`
+	Generator string          `json:"generator,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Tests     []Failure       `json:"tests"`
+	Count     int             `json:"count"`
+}
+
+// NewFailure returns the failure for a triple with the provided
+// orders and outputs (see lib.Triple.Merge).  It returns false if the
+// outputs of every pair of orders swapping the first two operations
+// are the same.
+func NewFailure(input string, t lib.Triple, orders map[string][]string, outputs map[string]string) (Failure, bool) {
+	for _, order := range lib.TripleOrders {
+		swapped := order[1:2] + order[0:1] + order[2:]
+		if order < swapped && outputs[order] != outputs[swapped] {
+			return Failure{
+				Input:   input,
+				Triple:  t,
+				Orders:  map[string][]string{order: orders[order], swapped: orders[swapped]},
+				Outputs: map[string]string{order: outputs[order], swapped: outputs[swapped]},
+			}, true
+		}
+	}
+	return Failure{}, false
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Each row is encoded as
+// [input, a, b, c, orders, outputs] where orders maps the two orders
+// of the reproduction to the operations applied and outputs maps
+// them to the value each converges to.
+func (f *Failure) UnmarshalJSON(data []byte) error {
+	var row []json.RawMessage
+	if err := json.Unmarshal(data, &row); err != nil {
+		return err
+	}
+	if len(row) != 6 {
+		return fmt.Errorf("triples: expected 6 columns, got %d", len(row))
+	}
+
+	fields := []interface{}{&f.Input, &f.Triple[0], &f.Triple[1], &f.Triple[2], &f.Orders, &f.Outputs}
+	for kk := range row {
+		if err := json.Unmarshal(row[kk], fields[kk]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (f Failure) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{f.Input, f.Triple[0], f.Triple[1], f.Triple[2], f.Orders, f.Outputs})
+}
+
+// ReadFailures reads a list of known failures
+func ReadFailures(r io.Reader) (*Failures, error) {
+	var f Failures
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+	if f.Format != "triples_failures" {
+		return nil, fmt.Errorf("triples: unknown format %q", f.Format)
+	}
+	if f.Version > suite.Version {
+		return nil, fmt.Errorf("triples: unsupported version %d", f.Version)
+	}
+	if f.Count != len(f.Tests) {
+		return nil, fmt.Errorf("triples: expected %d failures, got %d", f.Count, len(f.Tests))
+	}
+	return &f, nil
+}
+
+// LoadFailures reads a list of known failures from a file
+func LoadFailures(path string) (*Failures, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadFailures(f)
+}
+
+// FailuresFile returns the path of the known failures of the bundled
+// triples suite
+func FailuresFile() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "json", "triples_failures.json")
+}
+
+// Verify checks that the failure reproduces with changes.Merge: the
+// first two operations of each order converge when merged on their
+// own and the orders apply the recorded operations and diverge to the
+// recorded outputs.
+func (f Failure) Verify() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("triples: %v panicked: %v", f, r)
+		}
+	}()
+
+	if err := f.Validate(); err != nil {
+		return err
+	}
+
+	c := lib.Compact{}
+	order := f.orders()[0]
+	_, x, err := c.DecodeE(f.Triple[order[0]-'a'])
+	if err != nil {
+		return err
+	}
+	_, y, err := c.DecodeE(f.Triple[order[1]-'a'])
+	if err != nil {
+		return err
+	}
+	if order[0] > order[1] {
+		x, y = y, x
+	}
+	yx, xy := changes.Merge(x, y)
+	if l, r := c.Apply(f.Input, changes.ChangeSet{x, yx}), c.Apply(f.Input, changes.ChangeSet{y, xy}); l != r {
+		return fmt.Errorf("triples: %v: %s diverges after two operations: %q != %q", f, order, l, r)
+	}
+
+	orders, outputs, err := f.Triple.Merge()
+	if err != nil {
+		return err
+	}
+	for _, order := range f.orders() {
+		if outputs[order] != f.Outputs[order] {
+			return fmt.Errorf("triples: %v: %s converges to %q", f, order, outputs[order])
+		}
+		if !equal(orders[order], f.Orders[order]) {
+			return fmt.Errorf("triples: %v: %s = %q", f, order, orders[order])
+		}
+	}
+	return nil
+}
+
+// Validate checks that the failure is well formed without merging
+// anything: the operations of the triple apply to the input and the
+// two orders of the reproduction swap the first two operations, apply
+// to the input and diverge to the recorded outputs.
+func (f Failure) Validate() (err error) {
+	c := lib.Compact{}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("triples: %v: %v", f, r)
+		}
+	}()
+
+	for _, op := range f.Triple {
+		if start, _, err := c.DecodeE(op); err != nil {
+			return err
+		} else if c.EncodeValue(start) != f.Input {
+			return fmt.Errorf("triples: %v: %q does not apply to the input", f, op)
+		}
+	}
+
+	orders := f.orders()
+	if len(orders) != 2 || len(f.Outputs) != 2 {
+		return fmt.Errorf("triples: %v: expected 2 orders", f)
+	}
+	if o := orders[0]; !isOrder(o) || orders[1] != o[1:2]+o[0:1]+o[2:] {
+		return fmt.Errorf("triples: %v: %s and %s do not swap the first two operations", f, orders[0], orders[1])
+	}
+	for _, order := range orders {
+		output, ok := f.Outputs[order]
+		if !ok {
+			return fmt.Errorf("triples: %v: missing output of %s", f, order)
+		}
+		start, cs, err := c.DecodeSeq(f.Orders[order])
+		if err != nil {
+			return err
+		}
+		if len(cs) > 0 && start != f.Input {
+			return fmt.Errorf("triples: %v: %s does not apply to the input", f, order)
+		}
+		if final := c.Apply(f.Input, cs); final != output {
+			return fmt.Errorf("triples: %v: %s converges to %q", f, order, final)
+		}
+	}
+	if f.Outputs[orders[0]] == f.Outputs[orders[1]] {
+		return fmt.Errorf("triples: %v: converges", f)
+	}
+	return nil
+}
+
+// orders returns the sorted orders of the reproduction
+func (f Failure) orders() []string {
+	result := []string{}
+	for order := range f.Orders {
+		result = append(result, order)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func isOrder(order string) bool {
+	for _, o := range lib.TripleOrders {
+		if o == order {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the JSON form of the failure
+func (f Failure) String() string {
+	data, err := f.MarshalJSON()
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
@@ -0,0 +1,222 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+// Package triples loads the triples suite and runs it against a
+// merge implementation.
+//
+// Each test of the suite is three concurrent operations on the same
+// input.  The operations are merged in every order (see
+// lib.TripleOrders): the first operation is applied as is, the second
+// is transformed against it and the third is transformed against
+// both.  The operation earlier in the triple is always the left side
+// of the merge, so ties are broken the same way in every order.  All
+// the orders must converge to the same final value, which catches
+// merge implementations that are only correct for pairs of
+// operations.
+//
+// Any dot-compatible merge implementation can be checked against the
+// bundled suite with:
+//
+//	func TestTriples(t *testing.T) {
+//	        triples.Run(t, changes.Merge)
+//	}
+package triples
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/dotchain/dataset/suite"
+	"github.com/dotchain/dataset/tools/lib"
+)
+
+// Test is a single row of the triples suite along with the
+// operations applied in each order.  Each order applies to the input
+// and converges to the final value.
+type Test struct {
+	Input, Final string
+	Triple       lib.Triple
+	Orders       map[string][]string
+}
+
+// Suite is a triples test suite.  The header fields are the same as
+// that of suite.Suite.
+type Suite struct {
+	Format    string          `json:"format"`
+	Version   int             `json:"version"`
+	Generator string          `json:"generator,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Tests     []Test          `json:"tests"`
+	Count     int             `json:"count"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Each row is encoded as
+// [input, final, a, b, c, orders] where orders maps each of
+// lib.TripleOrders to the operations applied.
+func (t *Test) UnmarshalJSON(data []byte) error {
+	var row []json.RawMessage
+	if err := json.Unmarshal(data, &row); err != nil {
+		return err
+	}
+	if len(row) != 6 {
+		return fmt.Errorf("triples: expected 6 columns, got %d", len(row))
+	}
+
+	fields := []interface{}{&t.Input, &t.Final, &t.Triple[0], &t.Triple[1], &t.Triple[2], &t.Orders}
+	for kk := range row {
+		if err := json.Unmarshal(row[kk], fields[kk]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (t Test) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{t.Input, t.Final, t.Triple[0], t.Triple[1], t.Triple[2], t.Orders})
+}
+
+// Read reads a triples test suite
+func Read(r io.Reader) (*Suite, error) {
+	var s Suite
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, err
+	}
+	if s.Format != "triples" {
+		return nil, fmt.Errorf("triples: unknown format %q", s.Format)
+	}
+	if s.Version > suite.Version {
+		return nil, fmt.Errorf("triples: unsupported version %d", s.Version)
+	}
+	if s.Count != len(s.Tests) {
+		return nil, fmt.Errorf("triples: expected %d tests, got %d", s.Count, len(s.Tests))
+	}
+	return &s, nil
+}
+
+// Load reads a triples test suite from a file
+func Load(path string) (*Suite, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// File returns the path of the triples suite bundled with this
+// package
+func File() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "json", "triples.json")
+}
+
+// Run runs the bundled triples suite against the provided merge
+// implementation
+func Run(t *testing.T, merge suite.MergeFunc) {
+	s, err := Load(File())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Run(t, merge)
+}
+
+// Run runs all the tests in the suite against the provided merge
+// implementation.  Each test is run as a subtest named by its index.
+func (s *Suite) Run(t *testing.T, merge suite.MergeFunc) {
+	for kk, test := range s.Tests {
+		test := test
+		t.Run(strconv.Itoa(kk), func(t *testing.T) {
+			if err := test.Verify(merge); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// Verify merges the triple in every order with the provided merge
+// implementation and checks that all orders converge to the final
+// value with the expected operations.
+func (t Test) Verify(merge suite.MergeFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("triples: %v panicked: %v", t, r)
+		}
+	}()
+
+	orders, outputs, err := t.Triple.MergeWith(merge)
+	if err != nil {
+		return err
+	}
+	for _, order := range lib.TripleOrders {
+		if outputs[order] != t.Final {
+			return fmt.Errorf("triples: %v: %s converges to %q", t, order, outputs[order])
+		}
+		if !equal(orders[order], t.Orders[order]) {
+			return fmt.Errorf("triples: %v: %s = %q", t, order, orders[order])
+		}
+	}
+	return nil
+}
+
+// Validate checks that the test is well formed without merging
+// anything: the operations of the triple apply to the input and the
+// operations of every order decode and converge to the final value.
+func (t Test) Validate() (err error) {
+	c := lib.Compact{}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("triples: %v: %v", t, r)
+		}
+	}()
+
+	for _, op := range t.Triple {
+		if start, _, err := c.DecodeE(op); err != nil {
+			return err
+		} else if c.EncodeValue(start) != t.Input {
+			return fmt.Errorf("triples: %v: %q does not apply to the input", t, op)
+		}
+	}
+
+	if len(t.Orders) != len(lib.TripleOrders) {
+		return fmt.Errorf("triples: %v: expected %d orders", t, len(lib.TripleOrders))
+	}
+	for _, order := range lib.TripleOrders {
+		seq, ok := t.Orders[order]
+		if !ok {
+			return fmt.Errorf("triples: %v: missing %s", t, order)
+		}
+		start, cs, err := c.DecodeSeq(seq)
+		if err != nil {
+			return err
+		}
+		if len(cs) > 0 && start != t.Input {
+			return fmt.Errorf("triples: %v: %s does not apply to the input", t, order)
+		}
+		if final := c.Apply(t.Input, cs); final != t.Final {
+			return fmt.Errorf("triples: %v: %s converges to %q", t, order, final)
+		}
+	}
+	return nil
+}
+
+func equal(actual, expected []string) bool {
+	return len(actual) == 0 && len(expected) == 0 || reflect.DeepEqual(actual, expected)
+}
+
+// String returns the JSON form of the test
+func (t Test) String() string {
+	data, err := t.MarshalJSON()
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
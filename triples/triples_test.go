@@ -0,0 +1,195 @@
+// Copyright (C) 2017 Ramesh Vyaghrapuri. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package triples_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dotchain/dataset/tools/lib"
+	"github.com/dotchain/dataset/triples"
+	"github.com/dotchain/dot/changes"
+)
+
+func TestDotMerge(t *testing.T) {
+	triples.Run(t, changes.Merge)
+}
+
+func TestKnownFailures(t *testing.T) {
+	f, err := triples.LoadFailures(triples.FailuresFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for kk, failure := range f.Tests {
+		if err := failure.Verify(); err != nil {
+			t.Error(kk, err)
+		}
+	}
+}
+
+func newTest(t *testing.T) triples.Test {
+	triple := lib.Triple{"(a=)bc", "a(b=x)c", "ab(c=y)"}
+	orders, _, err := triple.Merge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return triples.Test{Input: "abc", Final: "xy", Triple: triple, Orders: orders}
+}
+
+func TestVerify(t *testing.T) {
+	test := newTest(t)
+	if err := test.Verify(changes.Merge); err != nil {
+		t.Error("Unexpected error", err)
+	}
+
+	bad := func(l, r changes.Change) (changes.Change, changes.Change) {
+		return nil, nil
+	}
+	if err := test.Verify(bad); err == nil {
+		t.Error("Unexpected success")
+	}
+
+	panicky := func(l, r changes.Change) (changes.Change, changes.Change) {
+		panic("oops")
+	}
+	if err := test.Verify(panicky); err == nil {
+		t.Error("Unexpected success")
+	}
+
+	test.Orders["abc"] = test.Orders["cba"]
+	if err := test.Verify(changes.Merge); err == nil {
+		t.Error("Unexpected success")
+	}
+}
+
+func TestRead(t *testing.T) {
+	row, err := json.Marshal(newTest(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := triples.Read(strings.NewReader(`{
+		"format": "triples",
+		"version": 1,
+		"tests": [` + string(row) + `],
+		"count": 1
+	}`))
+	if err != nil || len(s.Tests) != 1 || s.Tests[0].Final != "xy" || len(s.Tests[0].Orders) != 6 {
+		t.Fatal("Unexpected", s, err)
+	}
+	if err := s.Tests[0].Validate(); err != nil {
+		t.Error("Unexpected error", err)
+	}
+
+	invalid := []string{
+		`{"format": "triples", "version": 1, "tests": [], "count": 1}`,
+		`{"format": "triples", "version": 2, "tests": [], "count": 0}`,
+		`{"format": "compact", "version": 1, "tests": [], "count": 0}`,
+		`{"format": "triples", "version": 1, "tests": [["abc", "xy", "(a=)bc"]], "count": 1}`,
+	}
+	for _, data := range invalid {
+		if _, err := triples.Read(strings.NewReader(data)); err == nil {
+			t.Error("Unexpected success", data)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]func(test *triples.Test){
+		"final":   func(test *triples.Test) { test.Final = "yx" },
+		"triple":  func(test *triples.Test) { test.Triple[2] = "ab(=y)" },
+		"decode":  func(test *triples.Test) { test.Triple[0] = "(a=bc" },
+		"missing": func(test *triples.Test) { delete(test.Orders, "bca") },
+		"extra":   func(test *triples.Test) { test.Orders["abcd"] = nil },
+		"order":   func(test *triples.Test) { test.Orders["bca"] = test.Orders["bca"][:2] },
+		"input":   func(test *triples.Test) { test.Orders["bca"] = []string{"ab(=xy)"} },
+	}
+	for name, update := range tests {
+		test := newTest(t)
+		update(&test)
+		if err := test.Validate(); err == nil {
+			t.Error("Unexpected success", name)
+		}
+	}
+}
+
+func TestFailures(t *testing.T) {
+	test := newTest(t)
+	outputs := map[string]string{}
+	for _, order := range lib.TripleOrders {
+		outputs[order] = test.Final
+	}
+	if _, ok := triples.NewFailure(test.Input, test.Triple, test.Orders, outputs); ok {
+		t.Error("Unexpected failure for a converging triple")
+	}
+
+	outputs["cab"] = "x"
+	failure, ok := triples.NewFailure(test.Input, test.Triple, test.Orders, outputs)
+	if !ok || len(failure.Orders) != 2 || failure.Outputs["acb"] != "xy" || failure.Outputs["cab"] != "x" {
+		t.Fatal("Unexpected", failure, ok)
+	}
+	if err := failure.Validate(); err == nil {
+		t.Error("Unexpected success for an output that does not match its order")
+	}
+
+	failure.Orders["cab"] = []string{"(abc=x)"}
+	if err := failure.Validate(); err != nil {
+		t.Error("Unexpected error", err)
+	}
+	if err := failure.Verify(); err == nil {
+		t.Error("Unexpected success for a failure that does not reproduce")
+	}
+
+	row, err := json.Marshal(failure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := triples.ReadFailures(strings.NewReader(`{
+		"format": "triples_failures",
+		"version": 1,
+		"tests": [` + string(row) + `],
+		"count": 1
+	}`))
+	if err != nil || len(f.Tests) != 1 || f.Tests[0].Outputs["cab"] != "x" {
+		t.Fatal("Unexpected", f, err)
+	}
+
+	invalid := []string{
+		`{"format": "triples_failures", "version": 1, "tests": [], "count": 1}`,
+		`{"format": "triples", "version": 1, "tests": [], "count": 0}`,
+		`{"format": "triples_failures", "version": 1, "tests": [["abc", "(a=)bc"]], "count": 1}`,
+	}
+	for _, data := range invalid {
+		if _, err := triples.ReadFailures(strings.NewReader(data)); err == nil {
+			t.Error("Unexpected success", data)
+		}
+	}
+}
+
+func TestValidateFailure(t *testing.T) {
+	f, err := triples.LoadFailures(triples.FailuresFile())
+	if err != nil || len(f.Tests) == 0 {
+		t.Fatal("Unexpected", f, err)
+	}
+
+	tests := map[string]func(f *triples.Failure){
+		"triple":   func(f *triples.Failure) { f.Triple[2] = "(=)" },
+		"orders":   func(f *triples.Failure) { f.Orders = map[string][]string{} },
+		"swap":     func(f *triples.Failure) { f.Orders["cba"] = nil },
+		"output":   func(f *triples.Failure) { f.Outputs = map[string]string{"abc": "", "bac": ""} },
+		"missing":  func(f *triples.Failure) { f.Outputs = map[string]string{"acb": "", "cab": ""} },
+		"converge": func(f *triples.Failure) { f.Outputs = map[string]string{"abc": "x", "bac": "x"} },
+	}
+	for name, update := range tests {
+		failure := f.Tests[0]
+		failure.Orders = map[string][]string{"abc": failure.Orders["abc"], "bac": failure.Orders["bac"]}
+		failure.Outputs = map[string]string{"abc": failure.Outputs["abc"], "bac": failure.Outputs["bac"]}
+		update(&failure)
+		if err := failure.Validate(); err == nil {
+			t.Error("Unexpected success", name)
+		}
+	}
+}